@@ -0,0 +1,69 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"context"
+	"sync"
+)
+
+// DoAll performs all of the passed Requests concurrently, with at most
+// concurrency in flight at once (unbounded if not positive). The Responses and
+// errors are returned positionally aligned with the passed Requests, each
+// being the result of calling Do() on the Request. The passed context applies
+// to every Request on top of any context set on it, and once done no more
+// Requests are started. Every non-nil Response must be closed by the caller
+func DoAll(ctx context.Context, reqs []*Request, concurrency int) ([]*Response, []error) {
+	responses := make([]*Response, len(reqs))
+	errs := make([]error, len(reqs))
+	if concurrency <= 0 || concurrency > len(reqs) {
+		concurrency = len(reqs)
+	}
+
+	// Start the workers, each performing Requests until there are no more
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				responses[i], errs[i] = reqs[i].expect(reqs[i].doContext(ctx))
+			}
+		}()
+	}
+
+	// Hand out the Requests until all have started or the context is done
+	started := 0
+dispatch:
+	for ; started < len(reqs); started++ {
+		select {
+		case indexes <- started:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Requests that were never started fail with the contexts error
+	for i := started; i < len(reqs); i++ {
+		errs[i] = ctx.Err()
+	}
+	return responses, errs
+}
+
+// mergeContext returns a context derived from parent that is also done once
+// other is done. The returned CancelFunc must be called to release it
+func mergeContext(parent, other context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if other.Done() == nil {
+		return ctx, cancel // other can never be done
+	}
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
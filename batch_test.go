@@ -0,0 +1,66 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDoAll(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		if inFlight++; inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(req.URL.Path))
+	}))
+	defer s.Close()
+
+	c := New().WithBaseURL(s.URL)
+	paths := []string{"/0", "/1", "/2", "/3", "/4"}
+	var reqs []*Request
+	for _, p := range paths {
+		reqs = append(reqs, c.Get(p))
+	}
+
+	responses, errs := DoAll(context.Background(), reqs, 2)
+	for i, p := range paths {
+		if errs[i] != nil {
+			t.Fatalf("DoAll() error %d = %v", i, errs[i])
+		}
+		got, _ := responses[i].String()
+		responses[i].Close()
+		if got != p {
+			t.Errorf("DoAll() response %d = %q, want %q", i, got, p)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Errorf("DoAll() had %d requests in flight, want at most 2", maxInFlight)
+	}
+}
+
+func TestDoAll_Cancelled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := New().WithBaseURL(s.URL)
+	responses, errs := DoAll(ctx, []*Request{c.Get("/"), c.Get("/"), c.Get("/")}, 1)
+	for i := range errs {
+		if errs[i] == nil || responses[i] != nil {
+			t.Errorf("DoAll() result %d = %v, %v, want a cancelled error", i, responses[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when performing a Request to a host whose circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open for host")
+
+// WithCircuitBreaker enables a circuit breaker on the Client that is keyed by
+// host. Once threshold consecutive Requests to a host have failed, Requests to
+// it fail immediately with ErrCircuitOpen until cooldown has passed, at which
+// point a single probe Request is let through to decide whether to close the
+// circuit again. A Request fails if, after all retries, it errors or doesn't
+// receive an expected status code
+func (c *Client) WithCircuitBreaker(threshold int, cooldown time.Duration) *Client {
+	c.breaker = &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+	}
+	return c
+}
+
+// circuitBreaker tracks the consecutive failures of Requests per host
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+}
+
+// circuit is the state of the circuit breaker for a single host
+type circuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool // Whether a probe Request is in flight
+}
+
+// allow returns ErrCircuitOpen if a Request to the passed host shouldn't be
+// performed, otherwise allowing it through as a probe if the cooldown is over
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok || c.failures < b.threshold {
+		return nil
+	}
+	if c.probing || time.Since(c.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+	c.probing = true
+	return nil
+}
+
+// record records whether a Request to the passed host succeeded, opening the
+// circuit once the threshold of consecutive failures has been reached
+func (b *circuitBreaker) record(host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		delete(b.circuits, host)
+		return
+	}
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+	c.failures++
+	c.probing = false
+	if c.failures >= b.threshold {
+		c.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,40 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_WithCircuitBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	c := New().WithBaseURL(s.URL).WithCircuitBreaker(2, 50*time.Millisecond)
+	get := func() error {
+		return c.Get("/").WithExpectedStatus(http.StatusOK).Error()
+	}
+
+	// Two failures open the circuit, failing the third without an attempt
+	get()
+	get()
+	if err := get(); err != ErrCircuitOpen || attempts != 2 {
+		t.Fatalf("error = %v after %d attempts, want %v after 2 attempts", err, attempts, ErrCircuitOpen)
+	}
+
+	// After the cooldown a successful probe closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	status = http.StatusOK
+	if err := get(); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if err := get(); err != nil || attempts != 4 {
+		t.Errorf("error = %v after %d attempts, want nil after 4 attempts", err, attempts)
+	}
+}
@@ -0,0 +1,141 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Cache stores the responses to GET and HEAD Requests, keyed by their method
+// and URL, so that they can be revalidated using conditional requests
+type Cache interface {
+	// Get returns the CachedResponse stored under the passed key, if any
+	Get(key string) (*CachedResponse, bool)
+	// Set stores the passed CachedResponse under the passed key
+	Set(key string, res *CachedResponse)
+}
+
+// CachedResponse is a successful response stored in a Cache
+type CachedResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// WithCache sets a Cache on the Client that the responses of GET and HEAD
+// Requests are stored in when they carry an ETag or Last-Modified header.
+// Identical Requests then send If-None-Match and If-Modified-Since headers,
+// and if the server responds 304 Not Modified the cached response is returned
+// in its place. Cacheable bodies are buffered in memory in order to be
+// stored, unless they exceed the Requests WithMaxResponseBytes(...) limit
+func (c *Client) WithCache(cache Cache) *Client {
+	c.cache = cache
+	return c
+}
+
+// cacheDo performs the passed http Request using next, revalidating it against
+// and storing its response in the Requests Cache
+func (r *Request) cacheDo(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return next(req)
+	}
+
+	// Make the request conditional if it has been cached before
+	key := req.Method + " " + req.URL.String()
+	cached, ok := r.cache.Get(key)
+	if ok {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	res, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the cached response if it hasn't been modified
+	if ok && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		return cached.response(req), nil
+	}
+
+	// Otherwise store the response if it can be revalidated later. Bodies
+	// larger than the Requests WithMaxResponseBytes(...) limit are passed
+	// through unstored, so that the limit is still enforced as they're read
+	if res.StatusCode == http.StatusOK &&
+		(res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != "") {
+		var body io.Reader = res.Body
+		if r.maxResponse > 0 {
+			body = io.LimitReader(res.Body, r.maxResponse+1)
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		if r.maxResponse > 0 && int64(len(b)) > r.maxResponse {
+			res.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), res.Body), res.Body}
+			return res, nil
+		}
+		res.Body.Close()
+		r.cache.Set(key, &CachedResponse{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Header:     res.Header.Clone(),
+			Body:       b,
+		})
+		res.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	return res, nil
+}
+
+// response returns a new http Response for the passed http Request populated
+// from the CachedResponse
+func (c *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        c.Status,
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// NewMemoryCache returns a Cache that stores responses in memory
+func NewMemoryCache() Cache {
+	return &memoryCache{responses: make(map[string]*CachedResponse)}
+}
+
+// memoryCache is a Cache that stores responses in a map
+type memoryCache struct {
+	mu        sync.RWMutex
+	responses map[string]*CachedResponse
+}
+
+// Get returns the CachedResponse stored under the passed key, if any
+func (m *memoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res, ok := m.responses[key]
+	return res, ok
+}
+
+// Set stores the passed CachedResponse under the passed key
+func (m *memoryCache) Set(key string, res *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses[key] = res
+}
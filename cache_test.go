@@ -0,0 +1,55 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_WithCache(t *testing.T) {
+	var statuses []int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"some_etag"` {
+			statuses = append(statuses, http.StatusNotModified)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		statuses = append(statuses, http.StatusOK)
+		w.Header().Set("ETag", `"some_etag"`)
+		w.Write([]byte("some_body"))
+	}))
+	defer s.Close()
+
+	c := New().WithBaseURL(s.URL).WithCache(NewMemoryCache())
+	for i := 0; i < 2; i++ {
+		got, err := c.Get("/").WithExpectedStatus(http.StatusOK).String()
+		if err != nil {
+			t.Fatalf("Request.String() error = %v", err)
+		}
+		if got != "some_body" {
+			t.Errorf("Request.String() = %q, want %q", got, "some_body")
+		}
+	}
+	if len(statuses) != 2 || statuses[1] != http.StatusNotModified {
+		t.Errorf("server responded %v, want a 304 on the second request", statuses)
+	}
+}
+
+func TestClient_WithCacheMaxResponseBytes(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"some_etag"`)
+		w.Write(bytes.Repeat([]byte("a"), 1<<20))
+	}))
+	defer s.Close()
+
+	cache := NewMemoryCache()
+	_, err := New().WithBaseURL(s.URL).WithCache(cache).Get("/").WithMaxResponseBytes(10).Bytes()
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Request.Bytes() error = %v, want %v", err, ErrResponseTooLarge)
+	}
+	if cached, ok := cache.Get(http.MethodGet + " " + s.URL + "/"); ok {
+		t.Errorf("Cache stored %d bytes, want nothing stored", len(cached.Body))
+	}
+}
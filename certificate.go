@@ -0,0 +1,16 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import "crypto/tls"
+
+// WithClientCertificate sets a TLS client certificate to be presented when the
+// Request is performed, for endpoints that require mutual TLS. The Request is
+// sent using a clone of the http Client Transport with the certificate added
+// to its TLS config, so that the http Client and its Transport are left
+// untouched. Clones are cached and reused for the same certificate, meaning
+// connections are pooled per certificate rather than shared with the original
+// Transport, and are kept for the life of the process. The Transport must be
+// an *http.Transport or the Request will fail with ErrUnsupportedTransport
+func (r *Request) WithClientCertificate(cert tls.Certificate) *Request {
+	r.cert = &cert
+	return r
+}
@@ -0,0 +1,59 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequest_WithClientCertificate(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	s.StartTLS()
+	defer s.Close()
+	cert := s.TLS.Certificates[0] // Any certificate will do as it's not verified
+
+	c := New().WithClient(s.Client()).WithBaseURL(s.URL)
+	tests := []struct {
+		name    string
+		req     *Request
+		wantErr bool
+	}{
+		{
+			name:    "without certificate",
+			req:     c.Get("/"),
+			wantErr: true,
+		},
+		{
+			name: "with certificate",
+			req:  c.Get("/").WithClientCertificate(cert),
+		},
+		{
+			name: "with certificate reused",
+			req:  c.Get("/").WithClientCertificate(cert),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.WithExpectedStatus(http.StatusOK).Error()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Request.Error() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	base := s.Client().Transport
+	first, _ := transport(base, &cert, nil)
+	second, _ := transport(base, &cert, nil)
+	if first != second {
+		t.Errorf("transport() = %p, want the cached %p", second, first)
+	}
+	if got := len(base.(*http.Transport).TLSClientConfig.Certificates); got != 0 {
+		t.Errorf("base Transport has %d certificates, want 0", got)
+	}
+}
@@ -0,0 +1,40 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// ErrUnknownCharset is returned when a response body can't be decoded as the
+// charset in its Content-Type isn't supported
+var ErrUnknownCharset = errors.New("unknown charset")
+
+// StringDecoded is identical to the String() method but first transcodes the
+// response body to UTF-8 from the charset in the Content-Type of the response,
+// such as ISO-8859-1 or Shift_JIS. Bodies without a charset are assumed to be
+// UTF-8 already. When the charset isn't supported the body is returned as-is
+// alongside an error wrapping ErrUnknownCharset
+func (r *Response) StringDecoded() (string, error) {
+	b, err := r.Bytes()
+	if err != nil {
+		return "", err
+	}
+	_, params, _ := mime.ParseMediaType(r.ContentType())
+	charset := params["charset"]
+	if charset == "" || strings.EqualFold(charset, "utf-8") {
+		return string(b), nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(b), fmt.Errorf("unable to decode charset %q : %w", charset, ErrUnknownCharset)
+	}
+	decoded, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b), fmt.Errorf("unable to decode charset %q : %w", charset, err)
+	}
+	return string(decoded), nil
+}
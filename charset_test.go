@@ -0,0 +1,66 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponse_StringDecoded(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        string
+		wantErr     error
+	}{
+		{
+			name:        "iso-8859-1",
+			contentType: "text/html; charset=ISO-8859-1",
+			body:        []byte("caf\xe9"),
+			want:        "café",
+		},
+		{
+			name:        "shift_jis",
+			contentType: "text/plain; charset=Shift_JIS",
+			body:        []byte("\x93\xfa\x96\x7b"),
+			want:        "日本",
+		},
+		{
+			name:        "no charset",
+			contentType: "text/plain",
+			body:        []byte("café"),
+			want:        "café",
+		},
+		{
+			name:        "unknown charset",
+			contentType: "text/plain; charset=some-charset",
+			body:        []byte("caf\xe9"),
+			want:        "caf\xe9",
+			wantErr:     ErrUnknownCharset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(tt.body)
+			}))
+			defer s.Close()
+
+			res, err := New().WithBaseURL(s.URL).Get("/").Do()
+			if err != nil {
+				t.Fatalf("Request.Do() error = %v", err)
+			}
+			defer res.Close()
+			got, err := res.StringDecoded()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Response.StringDecoded() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Response.StringDecoded() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,13 +7,23 @@ import (
 
 // Client is an http.Client wrapper
 type Client struct {
-	client  *http.Client
-	baseURL string
-	headers []header
+	client     *http.Client
+	baseURL    string
+	headers    []header
+	middleware []Middleware
+	limiter    Limiter
+	breaker    *circuitBreaker
+	cache      Cache
+	observer   Observer
+	logger     func(event LogEvent)
 }
 
-// header is a struct that contains a key and a value
-type header struct{ key, value string }
+// header is a struct that contains a key and a value, and whether the value
+// should be added to any existing values rather than replacing them
+type header struct {
+	key, value string
+	add        bool
+}
 
 // New creates a new Client reference given a client timeout
 func New() *Client {
@@ -41,7 +51,8 @@ func (c *Client) WithTransport(transport http.RoundTripper) *Client {
 	return c
 }
 
-// WithBaseURL sets the baseURL on the Client
+// WithBaseURL sets the baseURL on the Client. The path of every Request is
+// joined onto it with a single slash, and it must include a scheme and host
 func (c *Client) WithBaseURL(url string) *Client {
 	c.baseURL = url
 	return c
@@ -53,5 +64,11 @@ func (c *Client) WithHeader(key, value string) *Client {
 	return c
 }
 
+// WithUserAgent sets the default User-Agent header for every Request created
+// from the Client. It may be overridden by WithUserAgent(...) on the Request
+func (c *Client) WithUserAgent(ua string) *Client {
+	return c.WithHeader("User-Agent", ua)
+}
+
 // Client is a getter that returns a reference to the underlying http Client
 func (c *Client) Client() *http.Client { return c.client }
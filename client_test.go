@@ -223,3 +223,33 @@ func TestClient_WithHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_WithUserAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+	}{
+		{
+			name:    "client default",
+			request: New().WithUserAgent("client_agent").Get("/"),
+			want:    "client_agent",
+		},
+		{
+			name:    "request override",
+			request: New().WithUserAgent("client_agent").Get("/").WithUserAgent("request_agent"),
+			want:    "request_agent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if err != nil {
+				t.Fatalf("Request.toHTTPRequest() error = %v", err)
+			}
+			if got := req.UserAgent(); got != tt.want {
+				t.Errorf("Request.toHTTPRequest() User-Agent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -71,17 +71,34 @@ func (c *Client) Delete(path string) *Request {
 	return c.Request(http.MethodDelete, path)
 }
 
+// Optionsf takes a format and a variadic of arguments and returns a
+// prepopulated Options request
+func (c *Client) Optionsf(format string, a ...interface{}) *Request {
+	return c.Options(fmt.Sprintf(format, a...))
+}
+
+// Options takes a path and returns a prepopulated Options request
+func (c *Client) Options(path string) *Request {
+	return c.Request(http.MethodOptions, path)
+}
+
 // Request creates a new Request copying configuration from the base Client
 func (c *Client) Request(method, path string) *Request {
 	r := &Request{
-		client:  c.client,
-		method:  method,
-		baseURL: c.baseURL,
-		path:    path,
-		headers: []header{},
+		client:     c.client,
+		method:     method,
+		baseURL:    c.baseURL,
+		path:       path,
+		headers:    []header{},
+		middleware: append([]Middleware(nil), c.middleware...),
+		limiter:    c.limiter,
+		breaker:    c.breaker,
+		cache:      c.cache,
+		observer:   c.observer,
+		logger:     c.logger,
 	}
 	for _, h := range c.headers {
-		r.headers = append(r.headers, header{key: h.key, value: h.value})
+		r.headers = append(r.headers, h)
 	}
 	return r
 }
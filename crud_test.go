@@ -792,6 +792,137 @@ func TestClient_Delete(t *testing.T) {
 	}
 }
 
+func TestClient_Optionsf(t *testing.T) {
+	type fields struct {
+		client  *http.Client
+		baseURL string
+		headers []header
+	}
+	type args struct {
+		format string
+		a      []interface{}
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   *Request
+	}{
+		{
+			name: "basic",
+			fields: fields{
+				client:  &http.Client{},
+				headers: []header{},
+			},
+			args: args{
+				format: "/%s/test/%d",
+				a:      []interface{}{"asdf", 9},
+			},
+			want: &Request{
+				client:  &http.Client{},
+				method:  "OPTIONS",
+				path:    "/asdf/test/9",
+				headers: []header{},
+			},
+		},
+		{
+			name: "with baseurl",
+			fields: fields{
+				client:  &http.Client{},
+				baseURL: "https://example.com",
+				headers: []header{},
+			},
+			args: args{
+				format: "/%s/test/%d",
+				a:      []interface{}{"asdf", 9},
+			},
+			want: &Request{
+				client:  &http.Client{},
+				method:  "OPTIONS",
+				baseURL: "https://example.com",
+				path:    "/asdf/test/9",
+				headers: []header{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{
+				client:  tt.fields.client,
+				baseURL: tt.fields.baseURL,
+				headers: tt.fields.headers,
+			}
+			if got := c.Optionsf(tt.args.format, tt.args.a...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.Optionsf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Options(t *testing.T) {
+	type fields struct {
+		client  *http.Client
+		baseURL string
+		headers []header
+	}
+	type args struct {
+		path string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   *Request
+	}{
+		{
+			name: "basic",
+			fields: fields{
+				client:  &http.Client{},
+				headers: []header{},
+			},
+			args: args{
+				path: "/asdf/test/9",
+			},
+			want: &Request{
+				client:  &http.Client{},
+				method:  "OPTIONS",
+				path:    "/asdf/test/9",
+				headers: []header{},
+			},
+		},
+		{
+			name: "with baseurl",
+			fields: fields{
+				client:  &http.Client{},
+				baseURL: "https://example.com",
+				headers: []header{},
+			},
+			args: args{
+				path: "/asdf/test/9",
+			},
+			want: &Request{
+				client:  &http.Client{},
+				method:  "OPTIONS",
+				baseURL: "https://example.com",
+				path:    "/asdf/test/9",
+				headers: []header{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{
+				client:  tt.fields.client,
+				baseURL: tt.fields.baseURL,
+				headers: tt.fields.headers,
+			}
+			if got := c.Options(tt.args.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.Options() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestClient_Request(t *testing.T) {
 	type fields struct {
 		client  *http.Client
@@ -0,0 +1,47 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// maxDebugBodyBytes is the largest body that is included in a debug dump
+const maxDebugBodyBytes = 64 << 10
+
+// WithDebug sets a writer on the Request that every attempt will be dumped to
+// in its wire format, the outgoing request before it's sent and the incoming
+// response once received. Both bodies are left intact for their normal use.
+// Bodies are only dumped when their length is known and at most 64KB, so that
+// streamed bodies and responses limited by WithMaxResponseBytes(...) are never
+// read in full by the dump, and otherwise only the headers are dumped
+func (r *Request) WithDebug(w io.Writer) *Request {
+	r.debug = w
+	return r
+}
+
+// debugDo performs the passed http Request using the passed send function,
+// dumping both the http Request and the http Response to the debug writer
+func (r *Request) debugDo(send func(*http.Request) (*http.Response, error), req *http.Request) (*http.Response, error) {
+	dump, err := httputil.DumpRequestOut(req, dumpBody(req.ContentLength))
+	if err != nil {
+		return nil, err
+	}
+	r.debug.Write(append(dump, '\n'))
+
+	res, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+	if dump, err = httputil.DumpResponse(res, dumpBody(res.ContentLength)); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	r.debug.Write(append(dump, '\n'))
+	return res, nil
+}
+
+// dumpBody returns whether a body of the passed length should be dumped
+func dumpBody(length int64) bool {
+	return length >= 0 && length <= maxDebugBodyBytes
+}
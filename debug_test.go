@@ -0,0 +1,99 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_WithDebug(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("X-Some-Header", "some_value")
+		w.Write(b)
+	}))
+	defer s.Close()
+
+	var debug bytes.Buffer
+	got, err := New().WithBaseURL(s.URL).Post("/").
+		WithString("some_body").
+		WithDebug(&debug).
+		String()
+	if err != nil {
+		t.Fatalf("Request.String() error = %v", err)
+	}
+	if got != "some_body" {
+		t.Errorf("Request.String() = %q, want %q", got, "some_body")
+	}
+	for _, want := range []string{"POST / HTTP/1.1", "X-Some-Header: some_value", "some_body"} {
+		if !strings.Contains(debug.String(), want) {
+			t.Errorf("debug output missing %q:\n%s", want, debug.String())
+		}
+	}
+}
+
+func TestRequest_WithDebugMaxResponseBytes(t *testing.T) {
+	body := strings.Repeat("a", 1<<20)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer s.Close()
+
+	var debug bytes.Buffer
+	_, err := New().WithBaseURL(s.URL).Get("/").
+		WithMaxResponseBytes(10).
+		WithDebug(&debug).
+		Bytes()
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Request.Bytes() error = %v, want %v", err, ErrResponseTooLarge)
+	}
+	if debug.Len() > maxDebugBodyBytes {
+		t.Errorf("debug output length = %d, want at most %d", debug.Len(), maxDebugBodyBytes)
+	}
+	if !strings.Contains(debug.String(), "HTTP/1.1 200 OK") {
+		t.Errorf("debug output missing response headers:\n%s", debug.String())
+	}
+}
+
+func TestRequest_WithDebugEvents(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: some_data\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-req.Context().Done():
+		}
+	}))
+	defer s.Close()
+	defer close(done)
+
+	var debug bytes.Buffer
+	res, err := New().WithBaseURL(s.URL).Get("/").
+		WithTimeout(time.Second).
+		WithDebug(&debug).
+		Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	defer res.Close()
+
+	events, _ := res.Events()
+	select {
+	case e := <-events:
+		if e.Data != "some_data" {
+			t.Errorf("Event.Data = %q, want %q", e.Data, "some_data")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Response.Events() received no event while the stream is open")
+	}
+	if !strings.Contains(debug.String(), "Content-Type: text/event-stream") {
+		t.Errorf("debug output missing response headers:\n%s", debug.String())
+	}
+}
@@ -4,5 +4,9 @@ go 1.16
 
 require (
 	github.com/cenkalti/backoff/v4 v4.1.1
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	golang.org/x/text v0.3.3
+	google.golang.org/protobuf v1.28.1
 	h12.io/socks v1.0.2
 )
@@ -0,0 +1,100 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// ErrForceHTTP2Proxy is returned when performing a Request that forces
+// HTTP/2 through a proxy, which isn't supported
+var ErrForceHTTP2Proxy = errors.New("forcing HTTP/2 through a proxy isn't supported")
+
+// http2Transports holds the HTTP/2 Transports created for each pair of base
+// Transport and client certificate so that they, and their pooled
+// connections, are reused by every Request forcing HTTP/2
+var http2Transports sync.Map // map[transportKey]*forcedHTTP2Transport
+
+// WithForceHTTP2 forces the Request to be sent over HTTP/2 without first
+// negotiating it. Plaintext http URLs are sent using HTTP/2 cleartext (h2c)
+// with prior knowledge, meaning the server must accept HTTP/2 on the
+// connection immediately, while https URLs are sent over TLS offering only h2
+// and fail against servers that don't support it. The Request is sent using a
+// separate HTTP/2 Transport that copies the TLS config of the http Client
+// Transport, leaving the http Client untouched, and whose connections are
+// pooled with every other Request forcing HTTP/2 for the life of the process.
+// The Transport must be an *http.Transport or ErrUnsupportedTransport is set
+// on the Request, and proxies set with WithProxy(...) aren't supported
+func (r *Request) WithForceHTTP2() *Request {
+	if r.err != nil {
+		return r
+	}
+	if r.client.Transport != nil {
+		if _, ok := r.client.Transport.(*http.Transport); !ok {
+			r.err = ErrUnsupportedTransport
+			return r
+		}
+	}
+	r.forceHTTP2 = true
+	return r
+}
+
+// forcedHTTP2Transport is an http RoundTripper that sends requests over
+// HTTP/2, using h2c for plaintext URLs
+type forcedHTTP2Transport struct {
+	h2c *http2.Transport
+	h2  *http2.Transport
+}
+
+// RoundTrip sends the passed http Request over HTTP/2
+func (t *forcedHTTP2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.h2.RoundTrip(req)
+}
+
+// http2Transport returns the cached HTTP/2 Transport that copies the TLS
+// config of the passed base Transport and presents the passed client
+// certificate, which may be nil, creating it if there isn't one yet
+func http2Transport(base http.RoundTripper, cert *tls.Certificate) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return nil, ErrUnsupportedTransport
+	}
+	key := transportKey{base: base}
+	if cert != nil {
+		key.cert = string(bytes.Join(cert.Certificate, nil))
+	}
+	if h2, ok := http2Transports.Load(key); ok {
+		return h2.(*forcedHTTP2Transport), nil
+	}
+
+	cfg := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		cfg = t.TLSClientConfig.Clone()
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	cfg.NextProtos = []string{http2.NextProtoTLS}
+	h2 := &forcedHTTP2Transport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr) // Dial without TLS for h2c
+			},
+		},
+		h2: &http2.Transport{TLSClientConfig: cfg},
+	}
+	stored, _ := http2Transports.LoadOrStore(key, h2)
+	return stored.(*forcedHTTP2Transport), nil
+}
@@ -0,0 +1,81 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestRequest_WithForceHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Proto))
+	})
+	cleartext := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer cleartext.Close()
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "cleartext",
+			request: New().WithBaseURL(cleartext.URL).Get("/").WithForceHTTP2(),
+			want:    "HTTP/2.0",
+		},
+		{
+			name:    "cleartext not forced",
+			request: New().WithBaseURL(cleartext.URL).Get("/"),
+			want:    "HTTP/1.1",
+		},
+		{
+			name:    "tls",
+			request: New().WithClient(tlsServer.Client()).WithBaseURL(tlsServer.URL).Get("/").WithForceHTTP2(),
+			want:    "HTTP/2.0",
+		},
+		{
+			name:    "unsupported transport",
+			request: New().WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)).Get(cleartext.URL).WithForceHTTP2(),
+			wantErr: true,
+		},
+		{
+			name:    "proxy",
+			request: New().WithBaseURL(cleartext.URL).Get("/").WithForceHTTP2().WithProxy("http://127.0.0.1:8080"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.request.String()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request.String() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Request.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithForceHTTP2KeepsError(t *testing.T) {
+	err := New().WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)).Post("/").
+		WithForceHTTP2().
+		WithJSON(map[string]string{"key": "value"}).
+		Error()
+	if err != ErrUnsupportedTransport {
+		t.Errorf("Request.Error() error = %v, want %v", err, ErrUnsupportedTransport)
+	}
+}
+
+// roundTripperFunc is an http RoundTripper implemented by a function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,35 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// WithIdempotencyKey sets the Idempotency-Key header on the Request. Servers
+// that support it will only apply a request with a given key once, which is
+// what makes retrying non-idempotent methods such as POST with WithRetry(...)
+// safe, as every attempt is sent with the same key
+func (r *Request) WithIdempotencyKey(key string) *Request {
+	r.idempotent = false
+	return r.WithHeader("Idempotency-Key", key)
+}
+
+// WithAutoIdempotencyKey is identical to the WithIdempotencyKey(...) method
+// but generates a random UUID as the key. A new key is generated each time the
+// Request is performed and reused by every attempt of it, so clones made with
+// Clone() and repeated calls to Do() are each sent with a key of their own
+func (r *Request) WithAutoIdempotencyKey() *Request {
+	r.idempotent = true
+	return r
+}
+
+// newUUID returns a new random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("unable to generate uuid : %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
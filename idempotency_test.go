@@ -0,0 +1,117 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRequest_WithAutoIdempotencyKey(t *testing.T) {
+	var keys []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	New().WithBaseURL(s.URL).Post("/").
+		WithString("some_body").
+		WithAutoIdempotencyKey().
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(3).
+		WithBackoff(time.Millisecond, 1).
+		Error()
+	if len(keys) != 3 {
+		t.Fatalf("server received %d attempts, want 3", len(keys))
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuid.MatchString(keys[0]) {
+		t.Errorf("Idempotency-Key = %q, want a version 4 uuid", keys[0])
+	}
+	for i, key := range keys {
+		if key != keys[0] {
+			t.Errorf("attempt %d Idempotency-Key = %q, want %q", i+1, key, keys[0])
+		}
+	}
+}
+
+func TestRequest_WithAutoIdempotencyKeyClone(t *testing.T) {
+	var keys []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+	}))
+	defer s.Close()
+
+	template := New().WithBaseURL(s.URL).Post("/").WithAutoIdempotencyKey()
+	for _, path := range []string{"/first", "/second"} {
+		r := template.Clone()
+		r.path = path
+		if err := r.WithString(path).Error(); err != nil {
+			t.Fatalf("Request.Error() error = %v", err)
+		}
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] == keys[1] {
+		t.Errorf("clones sent Idempotency-Keys %q, want two different keys", keys)
+	}
+}
+
+func TestRequest_WithIdempotencyKey(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tests := []struct {
+		name  string
+		build func(r *Request) *Request
+		want  string // The expected key, a generated uuid when empty
+	}{
+		{
+			name:  "explicit",
+			build: func(r *Request) *Request { return r.WithIdempotencyKey("some_key") },
+			want:  "some_key",
+		},
+		{
+			name: "explicit after auto",
+			build: func(r *Request) *Request {
+				return r.WithAutoIdempotencyKey().WithIdempotencyKey("some_key")
+			},
+			want: "some_key",
+		},
+		{
+			name: "auto after explicit",
+			build: func(r *Request) *Request {
+				return r.WithIdempotencyKey("some_key").WithAutoIdempotencyKey()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				keys = append(keys, req.Header.Get("Idempotency-Key"))
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer s.Close()
+
+			tt.build(New().WithBaseURL(s.URL).Post("/")).
+				WithString("some_body").
+				WithExpectedStatus(http.StatusOK).
+				WithRetry(3).
+				WithBackoff(time.Millisecond, 1).
+				Error()
+			if len(keys) != 3 {
+				t.Fatalf("server received %d attempts, want 3", len(keys))
+			}
+			if tt.want != "" && keys[0] != tt.want {
+				t.Errorf("Idempotency-Key = %q, want %q", keys[0], tt.want)
+			}
+			if tt.want == "" && !uuid.MatchString(keys[0]) {
+				t.Errorf("Idempotency-Key = %q, want a version 4 uuid", keys[0])
+			}
+			for i, key := range keys {
+				if key != keys[0] {
+					t.Errorf("attempt %d Idempotency-Key = %q, want %q", i+1, key, keys[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import "context"
+
+// Limiter rate limits the requests performed by a Client. It is satisfied by
+// *rate.Limiter from golang.org/x/time/rate
+type Limiter interface {
+	// Wait blocks until another request is allowed or the context is done
+	Wait(ctx context.Context) error
+}
+
+// WithLimiter sets a Limiter on the Client that every attempt of every Request
+// created from it, including retries, must wait on before being performed. By
+// default requests are not rate limited
+func (c *Client) WithLimiter(limiter Limiter) *Client {
+	c.limiter = limiter
+	return c
+}
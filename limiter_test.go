@@ -0,0 +1,41 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingLimiter is a Limiter that counts waits, blocking after the first n
+type blockingLimiter struct{ waits, n int }
+
+func (l *blockingLimiter) Wait(ctx context.Context) error {
+	if l.waits++; l.waits > l.n {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestClient_WithLimiter(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	l := &blockingLimiter{n: 2}
+	err := New().WithBaseURL(s.URL).WithLimiter(l).Get("/").
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(5).
+		WithBackoff(time.Millisecond, 1).
+		WithTimeout(50 * time.Millisecond).
+		Error()
+	if err != context.DeadlineExceeded {
+		t.Errorf("Request.Error() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if l.waits != 3 {
+		t.Errorf("Limiter waited %d times, want 3", l.waits)
+	}
+}
@@ -0,0 +1,27 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import "time"
+
+// LogEvent describes a single attempt of a Request
+type LogEvent struct {
+	Method     string
+	URL        string
+	Attempt    int           // The attempt number, starting at 1
+	StatusCode int           // The status code received, 0 if there was none
+	Duration   time.Duration // How long the attempt took
+	Err        error         // The error performing the attempt, if any
+}
+
+// WithLogger sets a function on the Client that is passed a LogEvent after
+// every attempt of every Request created from it. By default nothing is logged
+func (c *Client) WithLogger(logger func(event LogEvent)) *Client {
+	c.logger = logger
+	return c
+}
+
+// WithLogger sets a function on the Request that is passed a LogEvent after
+// every attempt, replacing any set on the Client
+func (r *Request) WithLogger(logger func(event LogEvent)) *Request {
+	r.logger = logger
+	return r
+}
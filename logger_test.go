@@ -0,0 +1,38 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequest_WithLogger(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusOK}
+	var attempts int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(statuses[attempts])
+		attempts++
+	}))
+	defer s.Close()
+
+	var events []LogEvent
+	err := New().WithBaseURL(s.URL).Get("/some_path").
+		WithLogger(func(event LogEvent) { events = append(events, event) }).
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(3).
+		WithBackoff(time.Millisecond, 1).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	if len(events) != len(statuses) {
+		t.Fatalf("logged %d events, want %d", len(events), len(statuses))
+	}
+	for i, event := range events {
+		if event.Method != http.MethodGet || event.URL != s.URL+"/some_path" ||
+			event.Attempt != i+1 || event.StatusCode != statuses[i] || event.Err != nil {
+			t.Errorf("event %d = %+v, want attempt %d with status %d", i, event, i+1, statuses[i])
+		}
+	}
+}
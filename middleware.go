@@ -0,0 +1,41 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import "net/http"
+
+// Middleware wraps every attempt made to perform a Request, including each
+// retry. It may modify the http Request or inspect the http Response, calling
+// next to continue down the chain and eventually perform the request
+type Middleware func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error)
+
+// WithMiddleware adds Middleware to the Client that will wrap every Request
+// created from it. Client Middleware runs before any set on the Request
+func (c *Client) WithMiddleware(middleware ...Middleware) *Client {
+	c.middleware = append(c.middleware, middleware...)
+	return c
+}
+
+// WithMiddleware adds Middleware to the Request. Middleware runs in the order
+// it is added, the first added being the outermost
+func (r *Request) WithMiddleware(middleware ...Middleware) *Request {
+	r.middleware = append(r.middleware, middleware...)
+	return r
+}
+
+// roundTrip performs a single attempt of the passed http Request using the
+// passed http Client, passing it through all Middleware on the Request
+func (r *Request) roundTrip(c *http.Client, req *http.Request) (*http.Response, error) {
+	send := func(req *http.Request) (*http.Response, error) { return r.sendProgress(c, req) }
+	next := send
+	if r.debug != nil {
+		next = func(req *http.Request) (*http.Response, error) { return r.debugDo(send, req) }
+	}
+	if r.cache != nil {
+		send := next
+		next = func(req *http.Request) (*http.Response, error) { return r.cacheDo(req, send) }
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		m, n := r.middleware[i], next
+		next = func(req *http.Request) (*http.Response, error) { return m(req, n) }
+	}
+	return next(req)
+}
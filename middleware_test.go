@@ -0,0 +1,47 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequest_WithMiddleware(t *testing.T) {
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if attempts++; attempts < 2 || req.Header.Get("X-Request-Id") != "some_id" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+			calls = append(calls, name)
+			return next(req)
+		}
+	}
+	setID := func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		req.Header.Set("X-Request-Id", "some_id")
+		return next(req)
+	}
+
+	err := New().WithBaseURL(s.URL).WithMiddleware(record("client")).Get("/").
+		WithMiddleware(record("request"), setID).
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(2).
+		WithBackoff(time.Millisecond, 1).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	want := []string{"client", "request", "client", "request"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,56 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WithMsgpack sets the MessagePack encoded passed interface as the body to be
+// used on the Request. It does nothing if an error has already occurred while
+// building the Request
+func (r *Request) WithMsgpack(body interface{}) *Request {
+	if r.err != nil {
+		return r
+	}
+	buf := bytes.NewBuffer(nil)
+	r.body = buf
+	r.err = msgpack.NewEncoder(buf).Encode(body)
+	return r.WithContentType("application/msgpack")
+}
+
+// Msgpack is a convenience method that handles executing, defer closing, and
+// decoding the MessagePack body into the passed interface before returning
+func (r *Request) Msgpack(out interface{}) error {
+	res, err := r.do()
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
+	}
+	return res.Msgpack(out)
+}
+
+// MsgpackWithError is identical to the Msgpack(...) method but also takes an
+// errOut interface for when the status code isn't expected. In this case the
+// response body will be decoded into the errOut interface and the boolean
+// (expected) will return false
+func (r *Request) MsgpackWithError(out interface{}, errOut interface{}) (bool, error) {
+	res, err := r.do()
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return false, res.Msgpack(errOut)
+	}
+	return true, res.Msgpack(out)
+}
+
+// Msgpack attempts to MessagePack decode the response body into the passed
+// interface
+func (r *Response) Msgpack(out interface{}) error {
+	return msgpack.NewDecoder(r.body).Decode(out)
+}
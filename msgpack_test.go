@@ -0,0 +1,67 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRequest_MsgpackWithError(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantExpected bool
+	}{
+		{
+			name:         "expected status",
+			status:       http.StatusOK,
+			wantExpected: true,
+		},
+		{
+			name:   "unexpected status",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				b, _ := ioutil.ReadAll(req.Body)
+				var in map[string]string
+				if err := msgpack.Unmarshal(b, &in); err != nil || req.Header.Get("Content-Type") != "application/msgpack" {
+					t.Errorf("server received invalid msgpack : %v", err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write(b) // Echo the body back
+			}))
+			defer s.Close()
+
+			var out, errOut map[string]string
+			expected, err := New().WithBaseURL(s.URL).Post("/").
+				WithMsgpack(map[string]string{"key": "some_value"}).
+				WithExpectedStatus(http.StatusOK).
+				MsgpackWithError(&out, &errOut)
+			if err != nil {
+				t.Fatalf("Request.MsgpackWithError() error = %v", err)
+			}
+			got := out["key"]
+			if !expected {
+				got = errOut["key"]
+			}
+			if expected != tt.wantExpected || got != "some_value" {
+				t.Errorf("Request.MsgpackWithError() = %v %q, want %v %q", expected, got, tt.wantExpected, "some_value")
+			}
+		})
+	}
+}
+
+func TestRequest_WithMsgpackKeepsError(t *testing.T) {
+	err := New().Post("/").WithProxy("ftp://127.0.0.1").
+		WithMsgpack(map[string]string{"key": "some_value"}).
+		Error()
+	if err == nil {
+		t.Errorf("Request.Error() error = nil, want the earlier WithProxy error")
+	}
+}
@@ -0,0 +1,74 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// FormFile is a file that will be written as a part of a multipart/form-data
+// body
+type FormFile struct {
+	FieldName   string
+	FileName    string
+	ContentType string // Defaults to application/octet-stream when empty
+	Reader      io.Reader
+}
+
+// quoteEscaper escapes the values used in a Content-Disposition header
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// WithMultipart encodes and sets the passed url.Values and FormFiles as the
+// multipart/form-data body to be used on the Request. The body is buffered in
+// memory so that it may be replayed on retries. It does nothing if an error
+// has already occurred while building the Request
+func (r *Request) WithMultipart(fields url.Values, files ...FormFile) *Request {
+	if r.err != nil {
+		return r
+	}
+	body := bytes.NewBuffer(nil)
+	w := multipart.NewWriter(body)
+	r.body = body
+	r.err = writeMultipart(w, fields, files)
+	return r.WithContentType(w.FormDataContentType())
+}
+
+// writeMultipart writes all passed fields and files to the multipart Writer
+// before closing it
+func writeMultipart(w *multipart.Writer, fields url.Values, files []FormFile) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range fields[key] {
+			if err := w.WriteField(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(f.FieldName), quoteEscaper.Replace(f.FileName)))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, f.Reader); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
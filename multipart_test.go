@@ -0,0 +1,58 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequest_WithMultipart(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if got := req.FormValue("some_field"); got != "some_value" {
+			t.Errorf("field some_field = %q, want %q", got, "some_value")
+		}
+		f, h, err := req.FormFile("some_file")
+		if err != nil {
+			t.Errorf("FormFile() error = %v", err)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		if string(b) != "some_contents" || h.Filename != "some.txt" ||
+			h.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("file = %q %q %q, want %q %q %q", b, h.Filename,
+				h.Header.Get("Content-Type"), "some_contents", "some.txt", "text/plain")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	err := New().WithBaseURL(s.URL).Post("/").
+		WithMultipart(url.Values{"some_field": {"some_value"}}, FormFile{
+			FieldName:   "some_file",
+			FileName:    "some.txt",
+			ContentType: "text/plain",
+			Reader:      strings.NewReader("some_contents"),
+		}).
+		WithExpectedStatus(http.StatusOK).
+		Error()
+	if err != nil {
+		t.Errorf("Request.Error() error = %v", err)
+	}
+}
+
+func TestRequest_WithMultipartKeepsError(t *testing.T) {
+	err := New().Post("/").WithProxy("ftp://127.0.0.1").
+		WithMultipart(url.Values{"key": {"value"}}).
+		Error()
+	if err == nil {
+		t.Errorf("Request.Error() error = nil, want the earlier WithProxy error")
+	}
+}
@@ -0,0 +1,31 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import "time"
+
+// Observer is notified as Requests are performed so that metrics and traces
+// can be recorded for them
+type Observer interface {
+	// OnStart is called once before the first attempt of a Request
+	OnStart(method, url string)
+	// OnAttempt is called before every attempt of a Request, starting at 1
+	OnAttempt(attempt int)
+	// OnComplete is called once a Request has finished with the status code
+	// of the final response (0 if there was none), the total duration of all
+	// attempts and any error that prevented a response from being received.
+	// When the final status code isn't expected the error is an *HTTPError
+	// without a Body, as the body is left for the caller to read
+	OnComplete(statusCode int, duration time.Duration, err error)
+}
+
+// WithObserver sets an Observer on the Client that is notified of every
+// Request created from it
+func (c *Client) WithObserver(observer Observer) *Client {
+	c.observer = observer
+	return c
+}
+
+// WithObserver sets an Observer on the Request, replacing any set on the Client
+func (r *Request) WithObserver(observer Observer) *Request {
+	r.observer = observer
+	return r
+}
@@ -0,0 +1,67 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingObserver is an Observer that records every call made to it
+type recordingObserver struct{ calls []string }
+
+func (o *recordingObserver) OnStart(method, url string) {
+	o.calls = append(o.calls, "start "+method)
+}
+
+func (o *recordingObserver) OnAttempt(attempt int) {
+	o.calls = append(o.calls, fmt.Sprintf("attempt %d", attempt))
+}
+
+func (o *recordingObserver) OnComplete(statusCode int, duration time.Duration, err error) {
+	o.calls = append(o.calls, fmt.Sprintf("complete %d %v", statusCode, err))
+}
+
+func TestClient_WithObserver(t *testing.T) {
+	var attempts int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if attempts++; attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	o := &recordingObserver{}
+	err := New().WithBaseURL(s.URL).WithObserver(o).Get("/").
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(3).
+		WithBackoff(time.Millisecond, 1).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	want := []string{"start GET", "attempt 1", "attempt 2", "attempt 3", "complete 200 <nil>"}
+	if !reflect.DeepEqual(o.calls, want) {
+		t.Errorf("Observer calls = %v, want %v", o.calls, want)
+	}
+}
+
+func TestClient_WithObserverHTTPError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	o := &recordingObserver{}
+	New().WithBaseURL(s.URL).WithObserver(o).Get("/").
+		WithExpectedStatus(http.StatusOK).
+		Error()
+	want := []string{"start GET", "attempt 1", "complete 404 Unexpected status received : 404 Not Found"}
+	if !reflect.DeepEqual(o.calls, want) {
+		t.Errorf("Observer calls = %v, want %v", o.calls, want)
+	}
+}
@@ -0,0 +1,47 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io"
+	"net/http"
+)
+
+// WithUploadProgress sets a callback on the Request that is called as the
+// request body is sent with the number of bytes written so far and the total
+// size of the body, or -1 if unknown. Progress restarts from zero whenever the
+// body is replayed for a retry
+func (r *Request) WithUploadProgress(progress func(bytesWritten, totalBytes int64)) *Request {
+	r.progress = progress
+	return r
+}
+
+// sendProgress performs the passed http Request using the passed http Client,
+// wrapping the body of the attempt so that the upload progress callback on the
+// Request is called as it's sent
+func (r *Request) sendProgress(c *http.Client, req *http.Request) (*http.Response, error) {
+	if r.progress != nil && req.Body != nil && req.Body != http.NoBody {
+		total := req.ContentLength
+		if total <= 0 {
+			total = -1
+		}
+		req.Body = &progressReader{ReadCloser: req.Body, total: total, progress: r.progress}
+	}
+	return c.Do(req)
+}
+
+// progressReader is an io.ReadCloser that reports the number of bytes read
+// from the body it wraps
+type progressReader struct {
+	io.ReadCloser
+	written, total int64
+	progress       func(bytesWritten, totalBytes int64)
+}
+
+// Read reads from the wrapped body, reporting the progress made
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.progress(p.written, p.total)
+	}
+	return n, err
+}
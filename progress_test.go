@@ -0,0 +1,73 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_WithUploadProgress(t *testing.T) {
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		if attempts++; attempts < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	body := strings.Repeat("some_bytes", 1024)
+	completed := 0
+	err := New().WithBaseURL(s.URL).Post("/").
+		WithString(body).
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(2).
+		WithBackoff(time.Millisecond, 1).
+		WithUploadProgress(func(bytesWritten, totalBytes int64) {
+			if totalBytes != int64(len(body)) || bytesWritten > totalBytes {
+				t.Errorf("progress = %d of %d, want at most %d of %d", bytesWritten, totalBytes, len(body), len(body))
+			}
+			if bytesWritten == totalBytes {
+				completed++
+			}
+		}).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	if completed != 2 {
+		t.Errorf("upload completed %d times, want 2", completed)
+	}
+}
+
+func TestRequest_WithUploadProgressDebug(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+	}))
+	defer s.Close()
+
+	body := strings.Repeat("some_bytes", 1024)
+	debug := &strings.Builder{}
+	var last int64
+	err := New().WithBaseURL(s.URL).Post("/").
+		WithString(body).
+		WithDebug(debug).
+		WithUploadProgress(func(bytesWritten, totalBytes int64) {
+			if debug.Len() == 0 {
+				t.Errorf("progress reported before the request was dumped")
+			}
+			last = bytesWritten
+		}).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	if last != int64(len(body)) {
+		t.Errorf("progress ended at %d, want %d", last, len(body))
+	}
+}
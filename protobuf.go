@@ -0,0 +1,61 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// WithProtobuf sets the Protobuf encoded passed message as the body to be used
+// on the Request. It does nothing if an error has already occurred while
+// building the Request
+func (r *Request) WithProtobuf(msg proto.Message) *Request {
+	if r.err != nil {
+		return r
+	}
+	b, err := proto.Marshal(msg)
+	r.body = bytes.NewBuffer(b)
+	r.err = err
+	return r.WithContentType("application/x-protobuf")
+}
+
+// Protobuf is a convenience method that handles executing, defer closing, and
+// decoding the Protobuf body into the passed message before returning
+func (r *Request) Protobuf(out proto.Message) error {
+	res, err := r.do()
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
+	}
+	return res.Protobuf(out)
+}
+
+// ProtobufWithError is identical to the Protobuf(...) method but also takes an
+// errOut message for when the status code isn't expected. In this case the
+// response body will be decoded into the errOut message and the boolean
+// (expected) will return false
+func (r *Request) ProtobufWithError(out proto.Message, errOut proto.Message) (bool, error) {
+	res, err := r.do()
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return false, res.Protobuf(errOut)
+	}
+	return true, res.Protobuf(out)
+}
+
+// Protobuf attempts to Protobuf decode the response body into the passed
+// message
+func (r *Response) Protobuf(out proto.Message) error {
+	b, err := ioutil.ReadAll(r.body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, out)
+}
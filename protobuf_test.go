@@ -0,0 +1,68 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRequest_ProtobufWithError(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantExpected bool
+	}{
+		{
+			name:         "expected status",
+			status:       http.StatusOK,
+			wantExpected: true,
+		},
+		{
+			name:   "unexpected status",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				b, _ := ioutil.ReadAll(req.Body)
+				var in wrapperspb.StringValue
+				if err := proto.Unmarshal(b, &in); err != nil || req.Header.Get("Content-Type") != "application/x-protobuf" {
+					t.Errorf("server received invalid protobuf : %v", err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write(b) // Echo the message back
+			}))
+			defer s.Close()
+
+			var out, errOut wrapperspb.StringValue
+			expected, err := New().WithBaseURL(s.URL).Post("/").
+				WithProtobuf(wrapperspb.String("some_value")).
+				WithExpectedStatus(http.StatusOK).
+				ProtobufWithError(&out, &errOut)
+			if err != nil {
+				t.Fatalf("Request.ProtobufWithError() error = %v", err)
+			}
+			got := out.GetValue()
+			if !expected {
+				got = errOut.GetValue()
+			}
+			if expected != tt.wantExpected || got != "some_value" {
+				t.Errorf("Request.ProtobufWithError() = %v %q, want %v %q", expected, got, tt.wantExpected, "some_value")
+			}
+		})
+	}
+}
+
+func TestRequest_WithProtobufKeepsError(t *testing.T) {
+	err := New().Post("/").WithProxy("ftp://127.0.0.1").
+		WithProtobuf(wrapperspb.String("some_value")).
+		Error()
+	if err == nil {
+		t.Errorf("Request.Error() error = nil, want the earlier WithProxy error")
+	}
+}
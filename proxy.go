@@ -1,6 +1,7 @@
 package httpclient /* import "s32x.com/httpclient" */
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 
@@ -37,3 +38,32 @@ func (c *Client) WithProxy(protocol, addr string) *Client {
 	}
 	return c
 }
+
+// WithProxy sets a proxy that the Request will be sent through, given as a URL
+// with a http, https, socks4 or socks5 scheme (e.g. socks5://127.0.0.1:1080).
+// Like WithClientCertificate(...) the Request is sent using a cached clone of
+// the http Client Transport, leaving the http Client untouched, so Requests
+// using the same proxy share a pool of connections that is separate from the
+// original Transport and is kept for the life of the process
+func (r *Request) WithProxy(proxyURL string) *Request {
+	if r.err != nil {
+		return r
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		r.err = fmt.Errorf("invalid proxy url %q : %w", proxyURL, err)
+		return r
+	}
+	switch u.Scheme {
+	case "http", "https", "socks4", "socks5":
+	default:
+		r.err = fmt.Errorf("invalid proxy url %q : unsupported scheme %q", proxyURL, u.Scheme)
+		return r
+	}
+	if u.Host == "" {
+		r.err = fmt.Errorf("invalid proxy url %q : missing host", proxyURL)
+		return r
+	}
+	r.proxy = u
+	return r
+}
@@ -0,0 +1,93 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequest_WithProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxied = req.URL.String()
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name    string
+		proxy   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "http proxy",
+			proxy: proxy.URL,
+			want:  "http://example.invalid/some_path",
+		},
+		{
+			name:    "unsupported scheme",
+			proxy:   "ftp://127.0.0.1:21",
+			wantErr: true,
+		},
+		{
+			name:    "missing host",
+			proxy:   "socks5://",
+			wantErr: true,
+		},
+		{
+			name:    "malformed url",
+			proxy:   "://127.0.0.1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxied = ""
+			c := New().WithBaseURL("http://example.invalid")
+			err := c.Get("/some_path").WithProxy(tt.proxy).WithExpectedStatus(http.StatusOK).Error()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request.Error() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if proxied != tt.want {
+				t.Errorf("proxy received %q, want %q", proxied, tt.want)
+			}
+			if c.Client().Transport != nil {
+				t.Errorf("Client Transport = %v, want it untouched", c.Client().Transport)
+			}
+		})
+	}
+}
+
+func TestRequest_WithProxyKeepsError(t *testing.T) {
+	var received bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = true
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		request func(r *Request) *Request
+	}{
+		{
+			name:    "json",
+			request: func(r *Request) *Request { return r.WithJSON(map[string]string{"key": "value"}) },
+		},
+		{
+			name:    "xml",
+			request: func(r *Request) *Request { return r.WithXML(struct{ Key string }{"value"}) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = false
+			err := tt.request(New().WithBaseURL(s.URL).Post("/").WithProxy("ftp://bad")).Error()
+			if err == nil {
+				t.Errorf("Request.Error() error = nil, want the WithProxy error")
+			}
+			if received {
+				t.Errorf("server received the request, want it never sent")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// WithQueryStruct adds the exported fields of the passed struct (or pointer to
+// a struct) as query parameters that will be used on the Request, as if each
+// were added with WithQuery(...). Fields are named by their `url` tag, falling
+// back to the field name, and are skipped when tagged `url:"-"` or when tagged
+// with the omitempty option and set to their zero value. Strings, bools and
+// numbers are supported, as are slices and arrays of them (added as repeated
+// keys) and pointers to them (skipped when nil)
+func (r *Request) WithQueryStruct(v interface{}) *Request {
+	values, err := structValues(v)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return r
+	}
+	return r.WithQueryValues(values)
+}
+
+// structValues encodes the fields of the passed struct into url.Values
+func structValues(v interface{}) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to encode query from non-struct type %T", v)
+	}
+
+	values := url.Values{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue // Unexported
+		}
+		name, opts := field.Name, ""
+		if tag, ok := field.Tag.Lookup("url"); ok {
+			if tag == "-" {
+				continue
+			}
+			if i := strings.IndexByte(tag, ','); i >= 0 {
+				tag, opts = tag[:i], tag[i+1:]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fv := rv.Field(i)
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if err := addQueryValue(values, name, fv); err != nil {
+			return nil, fmt.Errorf("unable to encode query field %s : %w", field.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// addQueryValue adds the passed reflected value to the url.Values under the
+// passed key, adding each element of slices and arrays individually
+func addQueryValue(values url.Values, key string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return addQueryValue(values, key, v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := addQueryValue(values, key, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		values.Add(key, v.String())
+	case reflect.Bool:
+		values.Add(key, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Add(key, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		values.Add(key, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32:
+		values.Add(key, strconv.FormatFloat(v.Float(), 'f', -1, 32))
+	case reflect.Float64:
+		values.Add(key, strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
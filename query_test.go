@@ -0,0 +1,77 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequest_WithQueryStruct(t *testing.T) {
+	page := 2
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "tagged fields",
+			v: struct {
+				Query string   `url:"q"`
+				Tags  []string `url:"tag"`
+				Page  *int     `url:"page"`
+				Limit uint     `url:"limit,omitempty"`
+				Skip  string   `url:"-"`
+			}{Query: "some_query", Tags: []string{"a", "b"}, Page: &page, Skip: "skipped"},
+			want: "page=2&q=some_query&tag=a&tag=b",
+		},
+		{
+			name: "untagged and nil fields",
+			v: &struct {
+				Active bool
+				Ratio  float64
+				Page   *int
+				hidden string
+			}{Active: true, Ratio: 0.5},
+			want: "Active=true&Ratio=0.5",
+		},
+		{
+			name:    "non-struct",
+			v:       "some_string",
+			wantErr: true,
+		},
+		{
+			name: "unsupported field",
+			v: struct {
+				Nested struct{} `url:"nested"`
+			}{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				got = req.URL.RawQuery
+			}))
+			defer s.Close()
+
+			err := New().WithBaseURL(s.URL).Get("/").WithQueryStruct(tt.v).Error()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request.WithQueryStruct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Request.WithQueryStruct() query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithQueryStructKeepsError(t *testing.T) {
+	err := New().Post("/").WithQueryStruct("some_string").
+		WithMsgpack(map[string]string{"key": "some_value"}).
+		Error()
+	if err == nil {
+		t.Errorf("Request.Error() error = nil, want the WithQueryStruct error")
+	}
+}
@@ -2,13 +2,20 @@ package httpclient /* import "s32x.com/httpclient" */
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cenkalti/backoff/v4"
 )
@@ -16,24 +23,57 @@ import (
 // Request is a type used for configuring, performing and decoding HTTP
 // requests
 type Request struct {
-	err            error
-	client         *http.Client // DO NOT MODIFY THIS CLIENT
-	method         string
-	baseURL        string
-	path           string
-	headers        []header
-	expectedStatus int // The statusCode that is expected for a success
-	retryCount     int // Number of times to retry
-	body           io.ReadWriter
-	ctx            context.Context
-}
-
-// WithBody sets the body on the request with the passed io.ReadWriter
-func (r *Request) WithBody(body io.ReadWriter) *Request {
+	err           error
+	client        *http.Client // DO NOT MODIFY THIS CLIENT
+	method        string
+	baseURL       string
+	path          string
+	headers       []header
+	query         url.Values
+	cookies       []*http.Cookie
+	expected      map[int]struct{}            // The statusCodes that are a success
+	expectSuccess bool                        // Whether any 2xx is a success
+	retryCount    int                         // Number of times to retry
+	backoff       *backoff.ExponentialBackOff // Delays between retries
+	jitter        float64                     // Randomization of backoff
+	maxRetryAfter time.Duration               // Cap on waits from Retry-After
+	retryPolicy   RetryPolicy                 // Overrides when to retry
+	retryOn       []statusRange               // The statusCodes that are retried
+	middleware    []Middleware
+	limiter       Limiter
+	breaker       *circuitBreaker
+	cache         Cache
+	observer      Observer
+	logger        func(event LogEvent)
+	debug         io.Writer // Where each attempt is dumped, if anywhere
+	progress      func(bytesWritten, totalBytes int64)
+	body          io.Reader
+	ctx           context.Context
+	timeout       time.Duration
+	noRedirects   bool
+	cert          *tls.Certificate // Client certificate presented, if any
+	proxy         *url.URL         // Proxy requests are sent through, if any
+	forceHTTP2    bool
+	idempotent    bool  // Whether to generate an Idempotency-Key per Do
+	maxResponse   int64 // Maximum number of response body bytes read
+}
+
+// WithBody sets the body on the request with the passed io.Reader. Unlike the
+// other body setters the body isn't buffered but streamed, meaning it can only
+// be replayed on retries if it's also an io.Seeker, in which case it is seeked
+// back to its current offset before each attempt and isn't closed once sent.
+// Requests with a body that can't be replayed aren't retried
+func (r *Request) WithBody(body io.Reader) *Request {
 	r.body = body
 	return r
 }
 
+// WithBodyContentType is identical to the WithBody(...) method but also sets
+// the content-type header on the Request
+func (r *Request) WithBodyContentType(body io.Reader, contentType string) *Request {
+	return r.WithBody(body).WithContentType(contentType)
+}
+
 // WithBytes sets the passed bytes as the body to be used on the Request
 func (r *Request) WithBytes(body []byte) *Request {
 	return r.WithBody(bytes.NewBuffer(body))
@@ -44,63 +84,266 @@ func (r *Request) WithString(body string) *Request {
 	return r.WithBody(bytes.NewBufferString(body))
 }
 
-// WithForm encodes and sets the passed url.Values as the body to be used on
-// the Request
+// WithForm encodes and sets the passed url.Values as the
+// application/x-www-form-urlencoded body to be used on the Request, replacing
+// any body set previously
 func (r *Request) WithForm(data url.Values) *Request {
 	return r.WithBody(bytes.NewBufferString(data.Encode())).
 		WithContentType("application/x-www-form-urlencoded")
 }
 
 // WithJSON sets the JSON encoded passed interface as the body to be used on
-// the Request
+// the Request. It does nothing if an error has already occurred while
+// building the Request
 func (r *Request) WithJSON(body interface{}) *Request {
-	r.body = bytes.NewBuffer(nil)
-	r.err = json.NewEncoder(r.body).Encode(body)
+	if r.err != nil {
+		return r
+	}
+	buf := bytes.NewBuffer(nil)
+	r.body = buf
+	r.err = json.NewEncoder(buf).Encode(body)
 	return r.WithContentType("application/json")
 }
 
 // WithXML sets the XML encoded passed interface as the body to be used on the
-// Request
+// Request. It does nothing if an error has already occurred while building
+// the Request
 func (r *Request) WithXML(body interface{}) *Request {
-	r.body = bytes.NewBuffer(nil)
-	r.err = xml.NewEncoder(r.body).Encode(body)
+	if r.err != nil {
+		return r
+	}
+	buf := bytes.NewBuffer(nil)
+	r.body = buf
+	r.err = xml.NewEncoder(buf).Encode(body)
 	return r.WithContentType("application/xml")
 }
 
+// WithGzip gzip compresses the body set on the Request and sets the
+// Content-Encoding header to match. It must be called after the body has been
+// set (by WithJSON(...) etc) and does nothing if no body is set
+func (r *Request) WithGzip() *Request {
+	if r.body == nil || r.err != nil {
+		return r
+	}
+	body := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(body)
+	if _, r.err = io.Copy(zw, r.body); r.err != nil {
+		return r
+	}
+	if r.err = zw.Close(); r.err != nil {
+		return r
+	}
+	r.body = body
+	return r.WithHeader("Content-Encoding", "gzip")
+}
+
+// WithBaseURL sets the baseURL on the Request, overriding the one set on the
+// Client. The path of the Request is joined onto it as with the Client
+func (r *Request) WithBaseURL(url string) *Request {
+	r.baseURL = url
+	return r
+}
+
 // WithContext sets the context on the Request
 func (r *Request) WithContext(ctx context.Context) *Request {
 	r.ctx = ctx
 	return r
 }
 
+// WithTimeout sets a timeout on the Request. The timeout bounds the entire
+// request including all retries and the reading of the response body, and is
+// applied on top of any context set with WithContext(...)
+func (r *Request) WithTimeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// WithMaxResponseBytes limits the number of bytes that will be read from the
+// response body. Reading beyond the limit returns ErrResponseTooLarge. By
+// default the size of the response body is unlimited
+func (r *Request) WithMaxResponseBytes(n int64) *Request {
+	r.maxResponse = n
+	return r
+}
+
+// WithFollowRedirects sets whether redirects are followed when performing the
+// Request, overriding the CheckRedirect policy of the http Client. When not
+// followed the redirect response itself is returned
+func (r *Request) WithFollowRedirects(follow bool) *Request {
+	r.noRedirects = !follow
+	return r
+}
+
 // WithContentType sets the content-type that will be set in the headers on the
 // Request
 func (r *Request) WithContentType(typ string) *Request {
 	return r.WithHeader("Content-Type", typ)
 }
 
+// WithUserAgent sets the User-Agent header on the Request, overriding any
+// default set on the Client
+func (r *Request) WithUserAgent(ua string) *Request {
+	return r.WithHeader("User-Agent", ua)
+}
+
+// WithBasicAuth sets the Authorization header on the Request to the base64
+// encoded credentials as described in RFC 7617, replacing any set previously
+func (r *Request) WithBasicAuth(username, password string) *Request {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return r.WithHeader("Authorization", "Basic "+credentials)
+}
+
+// WithBearerToken sets the Authorization header on the Request to the passed
+// bearer token, replacing any set previously
+func (r *Request) WithBearerToken(token string) *Request {
+	return r.WithHeader("Authorization", "Bearer "+token)
+}
+
 // WithHeader sets a header that will be used on the Request
 func (r *Request) WithHeader(key, value string) *Request {
 	r.headers = append(r.headers, header{key: key, value: value})
 	return r
 }
 
+// WithQuery adds a query parameter that will be used on the Request. Repeated
+// keys are appended rather than overwritten
+func (r *Request) WithQuery(key, value string) *Request {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// WithQueryValues adds all of the passed url.Values as query parameters that
+// will be used on the Request
+func (r *Request) WithQueryValues(values url.Values) *Request {
+	for key, vals := range values {
+		for _, value := range vals {
+			r.WithQuery(key, value)
+		}
+	}
+	return r
+}
+
+// WithHeaderAdd adds a header that will be used on the Request, keeping any
+// values previously set for the same key so that each is sent
+func (r *Request) WithHeaderAdd(key, value string) *Request {
+	r.headers = append(r.headers, header{key: key, value: value, add: true})
+	return r
+}
+
+// WithHeaders sets all of the passed headers that will be used on the
+// Request, as if set individually with WithHeader(...)
+func (r *Request) WithHeaders(headers map[string]string) *Request {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.WithHeader(key, headers[key])
+	}
+	return r
+}
+
+// WithCookie adds a cookie that will be sent on the Request
+func (r *Request) WithCookie(c *http.Cookie) *Request {
+	return r.WithCookies(c)
+}
+
+// WithCookies adds all passed cookies to be sent on the Request. They are sent
+// alongside any Cookie header set with WithHeader(...)
+func (r *Request) WithCookies(cookies ...*http.Cookie) *Request {
+	r.cookies = append(r.cookies, cookies...)
+	return r
+}
+
 // WithExpectedStatus sets the desired status-code that will be a success. If
 // the expected status code isn't received an error will be returned or the
 // request will be retried if a count has been set with WithRetry(...)
 func (r *Request) WithExpectedStatus(expectedStatusCode int) *Request {
-	r.expectedStatus = expectedStatusCode
+	return r.WithExpectedStatuses(expectedStatusCode)
+}
+
+// WithExpectedStatuses is identical to the WithExpectedStatus(...) method but
+// accepts a set of status-codes, any of which will be a success
+func (r *Request) WithExpectedStatuses(expectedStatusCodes ...int) *Request {
+	r.expected = nil
+	for _, code := range expectedStatusCodes {
+		if code <= 0 {
+			continue
+		}
+		if r.expected == nil {
+			r.expected = make(map[int]struct{}, len(expectedStatusCodes))
+		}
+		r.expected[code] = struct{}{}
+	}
+	return r
+}
+
+// WithExpectSuccess sets any 2xx status code as a success, as reported by
+// Response.IsSuccess(). Any other status code is then treated exactly as an
+// unexpected one is with WithExpectedStatus(...), returning an error or being
+// retried, unless it has also been expected with WithExpectedStatus(...)
+func (r *Request) WithExpectSuccess() *Request {
+	r.expectSuccess = true
 	return r
 }
 
-// WithRetry sets the desired number of retries on the Request
-// Note: In order to trigger retries you must set an expected status code with
-// the WithExpectedStatus(...) method
+// WithRetry sets the desired number of retries on the Request. Requests are
+// retried when performing them fails (excluding context cancellation) or, if
+// an expected status code has been set with the WithExpectedStatus(...) method,
+// when a different status code is received, unless the status codes to retry on
+// are set with WithRetryOnStatus(...)
 func (r *Request) WithRetry(retryCount int) *Request {
 	r.retryCount = retryCount
 	return r
 }
 
+// Clone returns a copy of the Request that can be configured and performed
+// independently of the original. Headers, query values, cookies, expected
+// status codes and middleware are deep-copied while the underlying http Client
+// is shared. Bodies set by WithBytes(...), WithString(...), WithJSON(...) and
+// the other buffered setters are re-buffered, as are *bytes.Reader and
+// *strings.Reader bodies, but any other body passed to WithBody(...) is shared
+// by both Requests. Any error stored while building the Request is copied too,
+// so a clone of a Request that failed to build fails in the same way
+func (r *Request) Clone() *Request {
+	clone := *r
+	clone.headers = append([]header(nil), r.headers...)
+	clone.cookies = append([]*http.Cookie(nil), r.cookies...)
+	clone.middleware = append([]Middleware(nil), r.middleware...)
+	clone.retryOn = append([]statusRange(nil), r.retryOn...)
+	if r.query != nil {
+		clone.query = make(url.Values, len(r.query))
+		for key, values := range r.query {
+			clone.query[key] = append([]string(nil), values...)
+		}
+	}
+	if r.expected != nil {
+		clone.expected = make(map[int]struct{}, len(r.expected))
+		for code := range r.expected {
+			clone.expected[code] = struct{}{}
+		}
+	}
+	if r.backoff != nil {
+		b := *r.backoff
+		clone.backoff = &b
+	}
+	switch body := r.body.(type) {
+	case *bytes.Buffer:
+		clone.body = bytes.NewBuffer(append([]byte(nil), body.Bytes()...))
+	case *bytes.Reader:
+		b := *body
+		clone.body = &b
+	case *strings.Reader:
+		b := *body
+		clone.body = &b
+	}
+	return &clone
+}
+
 // String is a convenience method that handles executing, defer closing, and
 // decoding the body into a string before returning
 func (r *Request) String() (string, error) {
@@ -114,27 +357,42 @@ func (r *Request) String() (string, error) {
 // Bytes is a convenience method that handles executing, defer closing, and
 // decoding the body into a slice of bytes before returning
 func (r *Request) Bytes() ([]byte, error) {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return nil, err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
-		return nil, fmt.Errorf("Unexpected status received : %s", res.Status())
+	if !r.isExpected(res.StatusCode()) {
+		return nil, newHTTPError(res)
 	}
 	return res.Bytes()
 }
 
+// Save is a convenience method that handles executing, defer closing, and
+// streaming the body into the passed io.Writer without buffering it in memory.
+// It returns the number of bytes written
+func (r *Request) Save(w io.Writer) (int64, error) {
+	res, err := r.do()
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return 0, newHTTPError(res)
+	}
+	return io.Copy(w, res.Body())
+}
+
 // JSON is a convenience method that handles executing, defer closing, and
 // decoding the JSON body into the passed interface before returning
 func (r *Request) JSON(out interface{}) error {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
-		return fmt.Errorf("Unexpected status received : %s", res.Status())
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
 	}
 	return res.JSON(out)
 }
@@ -144,12 +402,12 @@ func (r *Request) JSON(out interface{}) error {
 // body will be decoded into the errOut interface and the boolean (expected)
 // will return false
 func (r *Request) JSONWithError(out interface{}, errOut interface{}) (bool, error) {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return false, err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
+	if !r.isExpected(res.StatusCode()) {
 		return false, res.JSON(errOut)
 	}
 	return true, res.JSON(out)
@@ -158,13 +416,13 @@ func (r *Request) JSONWithError(out interface{}, errOut interface{}) (bool, erro
 // XML is a convenience method that handles executing, defer closing, and
 // decoding the XML body into the passed interface before returning
 func (r *Request) XML(out interface{}) error {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
-		return fmt.Errorf("Unexpected status received : %s", res.Status())
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
 	}
 	return res.XML(out)
 }
@@ -174,34 +432,90 @@ func (r *Request) XML(out interface{}) error {
 // body will be decoded into the errOut interface and the boolean (expected)
 // will return false
 func (r *Request) XMLWithError(out interface{}, errOut interface{}) (bool, error) {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return false, err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
+	if !r.isExpected(res.StatusCode()) {
 		return false, res.XML(errOut)
 	}
 	return true, res.XML(out)
 }
 
+// Decode is a convenience method that handles executing, defer closing, and
+// decoding the body into the passed interface, based on the Content-Type of
+// the response, before returning
+func (r *Request) Decode(out interface{}) error {
+	res, err := r.do()
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
+	}
+	return res.Decode(out)
+}
+
 // Error performs the request and returns any errors that result from the Do
 func (r *Request) Error() error {
-	res, err := r.Do()
+	res, err := r.do()
 	if err != nil {
 		return err
 	}
 	defer res.Close()
-	if r.expectedStatus > 0 && res.StatusCode() != r.expectedStatus {
-		return fmt.Errorf("Unexpected status received : %s", res.Status())
+	if !r.isExpected(res.StatusCode()) {
+		return newHTTPError(res)
 	}
 	return nil
 }
 
-// Do performs the base request and returns a populated Response
+// Do performs the base request and returns a populated Response. An HTTPError
+// is returned if the expected status code isn't received after all retries.
 // NOTE: As with the standard library, when calling Do you must remember to
 // close the response body : res.Body.Close()
 func (r *Request) Do() (*Response, error) {
+	return r.expect(r.do())
+}
+
+// DoRaw is identical to the Do() method but returns the standard library http
+// Response, for passing to code that expects one. Its Body is the decoded
+// body, decompressed and limited exactly as with Body(), and the caller owns
+// it : it must be closed with res.Body.Close(), which also releases the
+// Requests timeout and, as with Close(), drains it for connection reuse
+func (r *Request) DoRaw() (*http.Response, error) {
+	res, err := r.Do()
+	if err != nil {
+		return nil, err
+	}
+	raw := *res.res
+	raw.Body = rawBody{res: res}
+	return &raw, nil
+}
+
+// expect passes through the passed result of performing the Request, closing
+// the Response and returning an HTTPError if its status code isn't expected
+func (r *Request) expect(res *Response, err error) (*Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !r.isExpected(res.StatusCode()) {
+		defer res.Close()
+		return nil, newHTTPError(res)
+	}
+	return res, nil
+}
+
+// do performs the base request and returns a populated Response regardless of
+// whether or not the status code received is expected
+func (r *Request) do() (*Response, error) {
+	return r.doContext(context.Background())
+}
+
+// doContext is identical to the do() method but also binds the request to the
+// passed context, on top of any context set on the Request
+func (r *Request) doContext(ctx context.Context) (*Response, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
@@ -211,24 +525,140 @@ func (r *Request) Do() (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	c, err := r.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Notify the Observer of the start and completion of the request
+	var res *http.Response
+	if r.observer != nil {
+		start := time.Now()
+		r.observer.OnStart(req.Method, req.URL.String())
+		defer func() {
+			var statusCode int
+			observed := err
+			if res != nil {
+				statusCode = res.StatusCode
+				if err == nil && !r.isExpected(statusCode) {
+					observed = &HTTPError{StatusCode: statusCode, Status: res.Status}
+				}
+			}
+			r.observer.OnComplete(statusCode, time.Since(start), observed)
+		}()
+	}
+
+	// Fail fast if the circuit breaker for the host is open
+	if r.breaker != nil {
+		if err = r.breaker.allow(req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+
+	// Bind the request to the passed context and bound it and all of its
+	// retries by the timeout if one is set. Both are released once the
+	// Response has been closed
+	ctx, cancel := mergeContext(req.Context(), ctx)
+	if r.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, r.timeout)
+		cancelMerged := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelMerged()
+		}
+	}
+	req = req.WithContext(ctx)
 
 	// Perform the request with retries, returning the wrapped http.Response
-	res, err := doRetry(r.client, req, r.expectedStatus, r.retryCount)
+	res, err = r.doRetry(c, req)
+	if r.breaker != nil {
+		r.breaker.record(req.URL.Host, err == nil && r.isExpected(res.StatusCode))
+	}
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	return &Response{res: res}, nil
+	response := newResponse(res, r.maxResponse)
+	response.cancel = cancel
+	return response, nil
+}
+
+// isExpected returns whether the passed status code is a success for the
+// Request. When no status codes are expected, and any 2xx status code hasn't
+// been expected with WithExpectSuccess(), every status code is a success
+func (r *Request) isExpected(statusCode int) bool {
+	if r.expectSuccess && isSuccess(statusCode) {
+		return true
+	}
+	if len(r.expected) == 0 {
+		return !r.expectSuccess
+	}
+	_, ok := r.expected[statusCode]
+	return ok
 }
 
 // toHTTPRequest converts a Request to a standard HTTP Request. It assumes
 // there is no error on the request.
 func (r *Request) toHTTPRequest() (*http.Request, error) {
+	// Build the URL including any query parameters set on the Request
+	u, err := r.fullURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Send a copy of buffered bodies so that the body on the Request is never
+	// consumed and can be sent again, by another Do() or a Clone(). Hide the
+	// Close method of other seekable bodies as they may need to be replayed
+	body := r.body
+	seeker, seekable := body.(io.Seeker)
+	switch b := body.(type) {
+	case *bytes.Buffer:
+		body, seekable = bytes.NewReader(b.Bytes()), false
+	case *bytes.Reader:
+		c := *b
+		body, seekable = &c, false
+	case *strings.Reader:
+		c := *b
+		body, seekable = &c, false
+	}
+	if seekable {
+		body = struct{ io.Reader }{body}
+	}
+
 	// Generate a new http Request using client and passed Request
-	req, err := http.NewRequest(r.method, r.baseURL+r.path, r.body)
+	req, err := http.NewRequest(r.method, u, body)
 	if err != nil {
 		return nil, err
 	}
 
+	// Allow seekable bodies unknown to the standard library to be rewound, and
+	// send them with a Content-Length of the bytes remaining after the offset
+	if seekable {
+		reader := r.body
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		req.ContentLength = end - offset
+		if req.ContentLength == 0 {
+			req.Body, reader = http.NoBody, http.NoBody
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(reader), nil
+		}
+	}
+
 	// Apply a context if one is set on the Request
 	if r.ctx != nil {
 		req = req.WithContext(r.ctx)
@@ -236,46 +666,128 @@ func (r *Request) toHTTPRequest() (*http.Request, error) {
 
 	// Apply all headers from both the client and the Request
 	for _, h := range r.headers {
-		req.Header.Set(h.key, h.value)
+		if h.add {
+			req.Header.Add(h.key, h.value)
+		} else {
+			req.Header.Set(h.key, h.value)
+		}
+	}
+	if r.idempotent {
+		key, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	// Apply all cookies from the Request
+	for _, c := range r.cookies {
+		req.AddCookie(c)
 	}
 	return req, nil
 }
 
-// doRetry executes the passed http Request using the passed http Client and
-// retries as many times as specified
-func doRetry(c *http.Client, r *http.Request, expectedStatus, retryCount int) (*http.Response, error) {
-	// Create a ticker that will execute the exponential backoff algorithm
-	ticker := backoff.NewTicker(backoff.NewExponentialBackOff())
-
-	// Define the return variables
-	var res *http.Response
-	var err error
-
-	// Continuously retry HTTP requests
-	tries := 0
-	for range ticker.C {
-		tries++ // Increment the tries value to indicate which try num we're on
-
-		// Perform the request using the standard library
-		res, err = c.Do(r)
+// httpClient returns the http Client the Request should be performed with.
+// This is the Requests http Client unless it needs configuring for the
+// Request, in which case a shallow copy is configured instead
+func (r *Request) httpClient() (*http.Client, error) {
+	if !r.noRedirects && r.cert == nil && r.proxy == nil && !r.forceHTTP2 {
+		return r.client, nil
+	}
+	c := *r.client
+	if r.noRedirects {
+		c.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	switch {
+	case r.forceHTTP2 && r.proxy != nil:
+		return nil, ErrForceHTTP2Proxy
+	case r.forceHTTP2:
+		t, err := http2Transport(c.Transport, r.cert)
+		if err != nil {
+			return nil, err
+		}
+		c.Transport = t
+	case r.cert != nil || r.proxy != nil:
+		t, err := transport(c.Transport, r.cert, r.proxy)
 		if err != nil {
 			return nil, err
 		}
+		c.Transport = t
+	}
+	return &c, nil
+}
 
-		// If the status code isn't what we expect
-		if expectedStatus > 0 && expectedStatus != res.StatusCode {
-			if retryCount > tries {
-				continue // Retry if we should
-			}
-			err = fmt.Errorf("request failed to get expected status after %v retries", retryCount)
+// fullURL returns the URL the Request will be performed against, merging any
+// query parameters set on the Request with those already present in the path
+func (r *Request) fullURL() (string, error) {
+	u, err := joinURL(r.baseURL, r.path)
+	if err != nil {
+		return "", err
+	}
+	if len(r.query) > 0 {
+		q := u.Query()
+		for key, values := range r.query {
+			q[key] = append(q[key], values...)
 		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
 
-		// Stop the ticker and break out of the tick loop
-		ticker.Stop()
-		break
+// joinURL joins the passed path onto the passed base URL using a single slash
+// between them regardless of whether the base ends or the path begins with
+// one. Query parameters on the base come before any on the path. When there is
+// no base the path is used as the URL on its own
+func joinURL(base, path string) (*url.URL, error) {
+	if base == "" {
+		ref, err := url.Parse(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q : %w", path, err)
+		}
+		return ref, nil
 	}
+	u, err := url.Parse(base)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid base url %q : %w", base, err)
 	}
-	return res, nil
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q : missing scheme or host", base)
+	}
+	if abs, err := url.Parse(path); err == nil && abs.Host != "" {
+		return nil, fmt.Errorf("invalid path %q : must be relative to the base url", path)
+	}
+
+	// Parse the path rooted so that a colon in its first segment (v1:batch)
+	// isn't mistaken for a scheme
+	rooted := path
+	if path != "" && !strings.HasPrefix(path, "/") && path[0] != '?' && path[0] != '#' {
+		rooted = "/" + path
+	}
+	ref, err := url.Parse(rooted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q : %w", path, err)
+	}
+	if ref.Host != "" {
+		return nil, fmt.Errorf("invalid path %q : must be relative to the base url", path)
+	}
+
+	// Join the escaped paths so that any escaping on either is preserved
+	if ref.Path != "" {
+		joined := strings.TrimSuffix(u.EscapedPath(), "/") + "/" +
+			strings.TrimPrefix(ref.EscapedPath(), "/")
+		if u.Path, err = url.PathUnescape(joined); err != nil {
+			return nil, fmt.Errorf("invalid path %q : %w", path, err)
+		}
+		u.RawPath = joined
+	}
+	if ref.RawQuery != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += ref.RawQuery
+	}
+	u.Fragment = ref.Fragment
+	return u, nil
 }
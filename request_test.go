@@ -0,0 +1,886 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequest_Do_RetryReplaysBody(t *testing.T) {
+	type payload struct {
+		XMLName xml.Name `json:"-" xml:"payload"`
+		Name    string   `json:"name" xml:"name"`
+	}
+	tests := []struct {
+		name    string
+		request func(c *Client) *Request
+	}{
+		{
+			name: "bytes",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithBytes([]byte("some_bytes"))
+			},
+		},
+		{
+			name: "string",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithString("some_string")
+			},
+		},
+		{
+			name: "form",
+			request: func(c *Client) *Request {
+				return c.Post("/").
+					WithForm(url.Values{"replaced": {"value"}}).
+					WithForm(url.Values{"some_key": {"some_value"}})
+			},
+		},
+		{
+			name: "json",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithJSON(payload{Name: "some_name"})
+			},
+		},
+		{
+			name: "xml",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithXML(payload{Name: "some_name"})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				b, _ := ioutil.ReadAll(req.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				bodies = append(bodies, string(b))
+				if len(bodies) < 3 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer s.Close()
+
+			err := tt.request(New().WithBaseURL(s.URL)).
+				WithExpectedStatus(http.StatusOK).
+				WithRetry(3).
+				WithBackoff(time.Millisecond, 1).
+				Error()
+			if err != nil {
+				t.Fatalf("Request.Error() error = %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bodies) != 3 {
+				t.Fatalf("server received %d attempts, want 3", len(bodies))
+			}
+			if bodies[0] == "" || bodies[0] != bodies[2] || strings.Contains(bodies[0], "replaced") {
+				t.Errorf("attempt 3 body = %q, want %q", bodies[2], bodies[0])
+			}
+		})
+	}
+}
+
+func TestRequest_Do_RetryTransportErrors(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		attempts++
+		attempt := attempts
+		mu.Unlock()
+		if attempt < 3 {
+			// Drop the connection without writing a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack() error = %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	err := New().WithBaseURL(s.URL).Get("/").
+		WithRetry(3).
+		WithBackoff(time.Millisecond, 1).
+		Error()
+	if err != nil {
+		t.Fatalf("Request.Error() error = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Errorf("server received %d attempts, want 3", attempts)
+	}
+}
+
+func TestRequest_JSONWithError(t *testing.T) {
+	type body struct {
+		Message string `json:"message"`
+	}
+	tests := []struct {
+		name         string
+		status       int
+		expected     []int
+		wantExpected bool
+		wantOut      body
+		wantErrOut   body
+	}{
+		{
+			name:         "first expected status",
+			status:       http.StatusOK,
+			expected:     []int{http.StatusOK, http.StatusCreated},
+			wantExpected: true,
+			wantOut:      body{Message: "some_message"},
+		},
+		{
+			name:         "second expected status",
+			status:       http.StatusCreated,
+			expected:     []int{http.StatusOK, http.StatusCreated},
+			wantExpected: true,
+			wantOut:      body{Message: "some_message"},
+		},
+		{
+			name:       "unexpected status",
+			status:     http.StatusBadRequest,
+			expected:   []int{http.StatusOK, http.StatusCreated},
+			wantErrOut: body{Message: "some_message"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"message":"some_message"}`))
+			}))
+			defer s.Close()
+
+			var out, errOut body
+			expected, err := New().WithBaseURL(s.URL).Get("/").
+				WithExpectedStatuses(tt.expected...).
+				WithRetry(2).
+				WithBackoff(time.Millisecond, 1).
+				JSONWithError(&out, &errOut)
+			if err != nil {
+				t.Fatalf("Request.JSONWithError() error = %v", err)
+			}
+			if expected != tt.wantExpected {
+				t.Errorf("Request.JSONWithError() = %v, want %v", expected, tt.wantExpected)
+			}
+			if out != tt.wantOut || errOut != tt.wantErrOut {
+				t.Errorf("Request.JSONWithError() decoded %v and %v, want %v and %v", out, errOut, tt.wantOut, tt.wantErrOut)
+			}
+		})
+	}
+}
+
+func TestRequest_WithQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no query",
+			request: New().WithBaseURL("https://example.com").Get("/search"),
+			want:    "https://example.com/search",
+		},
+		{
+			name: "repeated keys",
+			request: New().WithBaseURL("https://example.com").Get("/search").
+				WithQuery("q", "some value").
+				WithQuery("q", "other"),
+			want: "https://example.com/search?q=some+value&q=other",
+		},
+		{
+			name: "merged with path query",
+			request: New().WithBaseURL("https://example.com").Get("/search?page=1").
+				WithQueryValues(url.Values{"page": {"2"}, "sort": {"asc"}}),
+			want: "https://example.com/search?page=1&page=2&sort=asc",
+		},
+		{
+			name: "invalid url",
+			request: New().WithBaseURL("://example.com").Get("/search").
+				WithQuery("q", "some_value"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request.toHTTPRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && req.URL.String() != tt.want {
+				t.Errorf("Request.toHTTPRequest() URL = %v, want %v", req.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithGzip(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      func(c *Client) *Request
+		want         string
+		wantEncoding string
+	}{
+		{
+			name: "compressed body",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithString("some_string").WithGzip()
+			},
+			want:         "some_string",
+			wantEncoding: "gzip",
+		},
+		{
+			name: "no body",
+			request: func(c *Client) *Request {
+				return c.Post("/").WithGzip()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body := io.Reader(req.Body)
+				encoding := req.Header.Get("Content-Encoding")
+				if encoding == "gzip" {
+					zr, err := gzip.NewReader(req.Body)
+					if err != nil {
+						t.Errorf("gzip.NewReader() error = %v", err)
+						return
+					}
+					body = zr
+				}
+				b, _ := ioutil.ReadAll(body)
+				if string(b) != tt.want || encoding != tt.wantEncoding {
+					t.Errorf("server received %q encoded as %q, want %q encoded as %q", b, encoding, tt.want, tt.wantEncoding)
+				}
+			}))
+			defer s.Close()
+
+			if err := tt.request(New().WithBaseURL(s.URL)).Error(); err != nil {
+				t.Errorf("Request.Error() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRequest_WithTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	start := time.Now()
+	err := New().WithBaseURL(s.URL).Get("/").
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(5).
+		WithBackoff(time.Millisecond, 1).
+		WithTimeout(50 * time.Millisecond).
+		Error()
+	if err == nil {
+		t.Errorf("Request.Error() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Request.Error() took %v, want under %v", elapsed, 500*time.Millisecond)
+	}
+}
+
+func TestRequest_Save(t *testing.T) {
+	want := strings.Repeat("some_bytes", 1024)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(want))
+	}))
+	defer s.Close()
+
+	var buf strings.Builder
+	n, err := New().WithBaseURL(s.URL).Get("/").Save(&buf)
+	if err != nil {
+		t.Fatalf("Request.Save() error = %v", err)
+	}
+	if n != int64(len(want)) || buf.String() != want {
+		t.Errorf("Request.Save() wrote %d bytes, want %d", n, len(want))
+	}
+}
+
+func TestRequest_WithHeaderAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    []string
+	}{
+		{
+			name: "added values",
+			request: New().Get("/").
+				WithHeaderAdd("X-Forwarded-For", "10.0.0.1").
+				WithHeaderAdd("X-Forwarded-For", "10.0.0.2"),
+			want: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "added to set value",
+			request: New().WithHeader("X-Forwarded-For", "10.0.0.1").Get("/").
+				WithHeaderAdd("X-Forwarded-For", "10.0.0.2"),
+			want: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "replaced by set value",
+			request: New().Get("/").
+				WithHeaderAdd("X-Forwarded-For", "10.0.0.1").
+				WithHeader("X-Forwarded-For", "10.0.0.2"),
+			want: []string{"10.0.0.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if err != nil {
+				t.Fatalf("Request.toHTTPRequest() error = %v", err)
+			}
+			if got := req.Header.Values("X-Forwarded-For"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Request.toHTTPRequest() header = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_HTTPError(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(r *Request) error
+	}{
+		{
+			name: "do",
+			do: func(r *Request) error {
+				_, err := r.Do()
+				return err
+			},
+		},
+		{
+			name: "bytes",
+			do: func(r *Request) error {
+				_, err := r.Bytes()
+				return err
+			},
+		},
+		{
+			name: "string",
+			do: func(r *Request) error {
+				_, err := r.String()
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("some_error"))
+			}))
+			defer s.Close()
+
+			err := tt.do(New().WithBaseURL(s.URL).Get("/").WithExpectedStatus(http.StatusOK))
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("error = %v, want *HTTPError", err)
+			}
+			if httpErr.StatusCode != http.StatusBadRequest || string(httpErr.Body) != "some_error" {
+				t.Errorf("HTTPError = %d %q, want %d %q", httpErr.StatusCode, httpErr.Body, http.StatusBadRequest, "some_error")
+			}
+		})
+	}
+}
+
+func TestRequest_WithCookie(t *testing.T) {
+	req, err := New().WithHeader("Cookie", "manual=value").Get("/").
+		WithCookie(&http.Cookie{Name: "first", Value: "some_value"}).
+		WithCookies(&http.Cookie{Name: "second", Value: "other value"}).
+		toHTTPRequest()
+	if err != nil {
+		t.Fatalf("Request.toHTTPRequest() error = %v", err)
+	}
+	want := []string{"manual", "first", "second"}
+	cookies := req.Cookies()
+	if len(cookies) != len(want) {
+		t.Fatalf("Request.toHTTPRequest() cookies = %v, want %v", cookies, want)
+	}
+	for i, c := range cookies {
+		if c.Name != want[i] {
+			t.Errorf("Request.toHTTPRequest() cookie %d = %v, want %v", i, c.Name, want[i])
+		}
+	}
+}
+
+func TestRequest_WithFollowRedirects(t *testing.T) {
+	tests := []struct {
+		name       string
+		follow     bool
+		wantStatus int
+	}{
+		{
+			name:       "followed",
+			follow:     true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not followed",
+			follow:     false,
+			wantStatus: http.StatusFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/from" {
+					http.Redirect(w, req, "/to", http.StatusFound)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer s.Close()
+
+			c := New().WithBaseURL(s.URL)
+			res, err := c.Get("/from").WithFollowRedirects(tt.follow).Do()
+			if err != nil {
+				t.Fatalf("Request.Do() error = %v", err)
+			}
+			defer res.Close()
+			if res.StatusCode() != tt.wantStatus {
+				t.Errorf("Request.Do() status = %v, want %v", res.StatusCode(), tt.wantStatus)
+			}
+			if c.Client().CheckRedirect != nil {
+				t.Errorf("Request.Do() modified the http Client")
+			}
+		})
+	}
+}
+
+func TestRequest_WithBody(t *testing.T) {
+	type seekable struct{ io.ReadSeeker }
+	tests := []struct {
+		name         string
+		body         io.Reader
+		wantAttempts int
+		wantLength   int64
+	}{
+		{
+			name:         "seekable body replayed",
+			body:         seekable{strings.NewReader("some_body")},
+			wantAttempts: 3,
+			wantLength:   9,
+		},
+		{
+			name: "seekable body replayed from offset",
+			body: func() io.Reader {
+				r := strings.NewReader("skip_some_body")
+				r.Seek(5, io.SeekStart)
+				return seekable{r}
+			}(),
+			wantAttempts: 3,
+			wantLength:   9,
+		},
+		{
+			name:         "streamed body not replayed",
+			body:         struct{ io.Reader }{strings.NewReader("some_body")},
+			wantAttempts: 1,
+			wantLength:   -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				b, _ := ioutil.ReadAll(req.Body)
+				bodies = append(bodies, string(b))
+				if req.ContentLength != tt.wantLength {
+					t.Errorf("server received Content-Length %d, want %d", req.ContentLength, tt.wantLength)
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer s.Close()
+
+			New().WithBaseURL(s.URL).Post("/").
+				WithBodyContentType(tt.body, "text/plain").
+				WithExpectedStatus(http.StatusOK).
+				WithRetry(3).
+				WithBackoff(time.Millisecond, 1).
+				Error()
+			if len(bodies) != tt.wantAttempts {
+				t.Fatalf("server received %d attempts, want %d", len(bodies), tt.wantAttempts)
+			}
+			for i, body := range bodies {
+				if body != "some_body" {
+					t.Errorf("attempt %d body = %q, want %q", i+1, body, "some_body")
+				}
+			}
+		})
+	}
+}
+
+func TestRequest_Clone(t *testing.T) {
+	var got []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		got = append(got, req.URL.RawQuery+" "+req.Header.Get("X-Test")+" "+string(b))
+	}))
+	defer s.Close()
+
+	base := New().WithBaseURL(s.URL).Post("/").
+		WithHeader("X-Test", "base").
+		WithQuery("page", "1").
+		WithString("some_body")
+	clone := base.Clone().WithHeader("X-Test", "clone").WithQuery("sort", "name")
+	if err := clone.Error(); err != nil {
+		t.Fatalf("clone Request.Error() error = %v", err)
+	}
+	if err := base.Error(); err != nil {
+		t.Fatalf("base Request.Error() error = %v", err)
+	}
+
+	want := []string{"page=1&sort=name clone some_body", "page=1 base some_body"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server received %v, want %v", got, want)
+	}
+}
+
+func Test_joinURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "double slash",
+			base: "https://example.com/api/",
+			path: "/users",
+			want: "https://example.com/api/users",
+		},
+		{
+			name: "missing slash",
+			base: "https://example.com/api",
+			path: "users",
+			want: "https://example.com/api/users",
+		},
+		{
+			name: "trailing slash kept",
+			base: "https://example.com",
+			path: "/users/",
+			want: "https://example.com/users/",
+		},
+		{
+			name: "query in base url",
+			base: "https://example.com/api?key=some_key",
+			path: "/users?page=1",
+			want: "https://example.com/api/users?key=some_key&page=1",
+		},
+		{
+			name: "escaped path",
+			base: "https://example.com/files",
+			path: "/a%2Fb",
+			want: "https://example.com/files/a%2Fb",
+		},
+		{
+			name: "colon in first segment",
+			base: "https://example.com/api",
+			path: "v1:batch",
+			want: "https://example.com/api/v1:batch",
+		},
+		{
+			name: "query only path",
+			base: "https://example.com/api",
+			path: "?page=1",
+			want: "https://example.com/api?page=1",
+		},
+		{
+			name:    "scheme relative path",
+			base:    "https://example.com",
+			path:    "//other.com/users",
+			wantErr: true,
+		},
+		{
+			name: "empty path",
+			base: "https://example.com/api",
+			want: "https://example.com/api",
+		},
+		{
+			name: "no base url",
+			path: "https://example.com/users",
+			want: "https://example.com/users",
+		},
+		{
+			name:    "missing scheme",
+			base:    "example.com",
+			path:    "/users",
+			wantErr: true,
+		},
+		{
+			name:    "absolute path",
+			base:    "https://example.com",
+			path:    "https://other.com/users",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := joinURL(tt.base, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("joinURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("joinURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithExpectSuccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		status  int
+		want    bool
+	}{
+		{
+			name:    "2xx",
+			request: New().Get("/").WithExpectSuccess(),
+			status:  http.StatusNoContent,
+			want:    true,
+		},
+		{
+			name:    "non-2xx",
+			request: New().Get("/").WithExpectSuccess(),
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "non-2xx also expected",
+			request: New().Get("/").WithExpectSuccess().WithExpectedStatus(http.StatusNotModified),
+			status:  http.StatusNotModified,
+			want:    true,
+		},
+		{
+			name:    "nothing expected",
+			request: New().Get("/"),
+			status:  http.StatusInternalServerError,
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.request.isExpected(tt.status); got != tt.want {
+				t.Errorf("Request.isExpected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+	}{
+		{
+			name:    "client base url",
+			request: New().WithBaseURL("https://api.example.com").Get("/v1/users"),
+			want:    "https://api.example.com/v1/users",
+		},
+		{
+			name: "request override",
+			request: New().WithBaseURL("https://api.example.com").Get("/v1/users").
+				WithBaseURL("https://other.example.com/"),
+			want: "https://other.example.com/v1/users",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if err != nil {
+				t.Fatalf("Request.toHTTPRequest() error = %v", err)
+			}
+			if req.URL.String() != tt.want {
+				t.Errorf("Request.toHTTPRequest() URL = %v, want %v", req.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_DoRaw(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("some_body"))
+		zw.Close()
+	}))
+	defer s.Close()
+
+	res, err := New().WithBaseURL(s.URL).Get("/").
+		WithHeader("Accept-Encoding", "gzip").
+		WithTimeout(time.Second).
+		DoRaw()
+	if err != nil {
+		t.Fatalf("Request.DoRaw() error = %v", err)
+	}
+	got, err := ioutil.ReadAll(res.Body)
+	if err != nil || string(got) != "some_body" {
+		t.Errorf("Request.DoRaw() body = %q %v, want %q", got, err, "some_body")
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Errorf("Request.DoRaw() Body.Close() error = %v", err)
+	}
+	if err := res.Request.Context().Err(); err == nil {
+		t.Errorf("Request.DoRaw() Body.Close() didn't release the request context")
+	}
+}
+
+func TestRequest_WithAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    []string
+	}{
+		{
+			name:    "basic auth",
+			request: New().Get("/").WithBasicAuth("user", "pass"),
+			want:    []string{"Basic dXNlcjpwYXNz"},
+		},
+		{
+			name:    "basic auth with colon in password",
+			request: New().Get("/").WithBasicAuth("Aladdin", "open:sesame"),
+			want:    []string{"Basic QWxhZGRpbjpvcGVuOnNlc2FtZQ=="},
+		},
+		{
+			name:    "bearer token",
+			request: New().Get("/").WithBearerToken("some_token"),
+			want:    []string{"Bearer some_token"},
+		},
+		{
+			name:    "bearer token replaces basic auth",
+			request: New().Get("/").WithBasicAuth("user", "pass").WithBearerToken("some_token"),
+			want:    []string{"Bearer some_token"},
+		},
+		{
+			name:    "basic auth replaces client header",
+			request: New().WithHeader("Authorization", "Bearer client_token").Get("/").WithBasicAuth("user", "pass"),
+			want:    []string{"Basic dXNlcjpwYXNz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if err != nil {
+				t.Fatalf("Request.toHTTPRequest() error = %v", err)
+			}
+			if got := req.Header.Values("Authorization"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Request.toHTTPRequest() Authorization = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    http.Header
+	}{
+		{
+			name:    "nil map",
+			request: New().WithHeader("X-Client", "client").Get("/").WithHeaders(nil),
+			want:    http.Header{"X-Client": {"client"}},
+		},
+		{
+			name:    "empty map",
+			request: New().WithHeader("X-Client", "client").Get("/").WithHeaders(map[string]string{}),
+			want:    http.Header{"X-Client": {"client"}},
+		},
+		{
+			name: "overwrites client and request headers",
+			request: New().WithHeader("X-Client", "client").Get("/").
+				WithHeader("X-Request", "request").
+				WithHeaders(map[string]string{"X-Client": "replaced", "X-Request": "replaced"}),
+			want: http.Header{"X-Client": {"replaced"}, "X-Request": {"replaced"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.toHTTPRequest()
+			if err != nil {
+				t.Fatalf("Request.toHTTPRequest() error = %v", err)
+			}
+			if !reflect.DeepEqual(req.Header, tt.want) {
+				t.Errorf("Request.toHTTPRequest() header = %v, want %v", req.Header, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_CloneAfterDo(t *testing.T) {
+	var got []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		got = append(got, string(b))
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		request *Request
+	}{
+		{
+			name:    "buffered body",
+			request: New().WithBaseURL(s.URL).Post("/").WithString("hello"),
+		},
+		{
+			name:    "bytes reader body",
+			request: New().WithBaseURL(s.URL).Post("/").WithBody(bytes.NewReader([]byte("hello"))),
+		},
+		{
+			name:    "strings reader body",
+			request: New().WithBaseURL(s.URL).Post("/").WithBody(strings.NewReader("hello")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			if err := tt.request.Error(); err != nil {
+				t.Fatalf("Request.Error() error = %v", err)
+			}
+			if err := tt.request.Clone().Error(); err != nil {
+				t.Fatalf("clone Request.Error() error = %v", err)
+			}
+			if err := tt.request.Error(); err != nil {
+				t.Fatalf("second Request.Error() error = %v", err)
+			}
+			if want := []string{"hello", "hello", "hello"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("server received %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -1,40 +1,183 @@
 package httpclient /* import "s32x.com/httpclient" */
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 // Response contains the raw http.Response reference OR any error that took
 // place while performing the request
-type Response struct{ res *http.Response }
+type Response struct {
+	res       *http.Response
+	body      io.ReadCloser      // The decoded body of the http Response
+	cancel    context.CancelFunc // Cancels the Requests timeout, if any
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// maxDrainBytes is the most unread body that is discarded when a Response is
+// closed so that its connection may be reused
+const maxDrainBytes = 64 << 10
+
+// ErrResponseTooLarge is returned when reading a response body that is larger
+// than the limit set with WithMaxResponseBytes(...)
+var ErrResponseTooLarge = errors.New("response body exceeds the maximum allowed size")
+
+// HTTPError is the error returned when a response is received with a status
+// code that isn't expected. It carries the status and body of the response
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+// newHTTPError creates a new HTTPError from the passed Response, reading its
+// body in full
+func newHTTPError(res *Response) *HTTPError {
+	body, _ := res.Bytes()
+	return &HTTPError{StatusCode: res.StatusCode(), Status: res.Status(), Body: body}
+}
 
-// Body returns the io Readcloser body on the Responses http Response
-func (r *Response) Body() io.ReadCloser { return r.res.Body }
+// Error returns a message containing the status of the unexpected response
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Unexpected status received : %s", e.Status)
+}
+
+// newResponse wraps the passed http Response, transparently decompressing
+// its body when required and limiting it to maxBytes if greater than zero
+func newResponse(res *http.Response, maxBytes int64) *Response {
+	body := decompressBody(res)
+	if maxBytes > 0 {
+		body = &limitedReader{ReadCloser: body, r: &io.LimitedReader{R: body, N: maxBytes + 1}}
+	}
+	return &Response{res: res, body: body}
+}
+
+// Body returns the io Readcloser body on the Responses http Response. The body
+// is decompressed if it was gzip or deflate encoded, and may be streamed (with
+// io.Copy etc) to avoid buffering large responses in memory
+func (r *Response) Body() io.ReadCloser { return r.body }
 
 // ContentType returns the content-type header found on the response
 func (r *Response) ContentType() string {
-	return r.Header().Get("Content-Type")
+	return r.GetHeader("Content-Type")
 }
 
 // Header returns the Header on the Responses http Response
 func (r *Response) Header() http.Header { return r.res.Header }
 
-// Close closes the response body on the Responses http Response
-func (r *Response) Close() error { return r.res.Body.Close() }
+// Trailer returns the trailing headers sent after the body of the Responses
+// http Response. Trailers are only received once the body has been read in
+// full, so this must be called after consuming the body (with Bytes(),
+// JSON(...) etc) for them to be populated
+func (r *Response) Trailer() http.Header { return r.res.Trailer }
+
+// GetHeader returns the first value of the named header on the Responses http
+// Response, or an empty string if it isn't present
+func (r *Response) GetHeader(key string) string { return r.res.Header.Get(key) }
+
+// Cookies returns all cookies set by Set-Cookie headers on the Responses http
+// Response
+func (r *Response) Cookies() []*http.Cookie { return r.res.Cookies() }
 
-// Response returns the http Response reference that is on the Response
+// Cookie returns the named cookie set on the Responses http Response or
+// http.ErrNoCookie if it isn't present
+func (r *Response) Cookie(name string) (*http.Cookie, error) {
+	for _, c := range r.Cookies() {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, http.ErrNoCookie
+}
+
+// Close closes the response body on the Responses http Response. When the
+// Content-Length of the body is known to be at most 64KB, any of it left
+// unread is first discarded so that the connection can be reused for another
+// request. Bodies of unknown length, such as chunked streams, are never
+// drained so that Close can't block on a server holding them open. Close is
+// safe to call more than once, with every call returning the result of the
+// first
+func (r *Response) Close() error {
+	r.closeOnce.Do(func() {
+		if r.res.ContentLength >= 0 && r.res.ContentLength <= maxDrainBytes {
+			io.CopyN(ioutil.Discard, r.res.Body, maxDrainBytes)
+		}
+		r.closeErr = r.body.Close()
+		if r.cancel != nil {
+			r.cancel()
+		}
+	})
+	return r.closeErr
+}
+
+// Response returns the http Response reference that is on the Response, for
+// inspecting details such as its TLS connection state. The Response still
+// owns the http Response, so its Body (which isn't decompressed) shouldn't be
+// read and the Response must still be closed rather than the Body
 func (r *Response) Response() *http.Response { return r.res }
 
+// rawBody is the body of the http Response returned by DoRaw(), reading the
+// decoded body of the Response and closing the Response when closed
+type rawBody struct{ res *Response }
+
+// Read reads from the decoded body of the Response
+func (b rawBody) Read(p []byte) (int, error) { return b.res.body.Read(p) }
+
+// Close closes the Response
+func (b rawBody) Close() error { return b.res.Close() }
+
 // Status returns the status message on the Response
 func (r *Response) Status() string { return r.res.Status }
 
 // StatusCode returns the status code found on the Response
 func (r *Response) StatusCode() int { return r.res.StatusCode }
 
+// IsSuccess returns whether the status code on the Response is a 2xx success
+func (r *Response) IsSuccess() bool { return isSuccess(r.res.StatusCode) }
+
+// IsClientError returns whether the status code on the Response is a 4xx
+// client error
+func (r *Response) IsClientError() bool { return isClientError(r.res.StatusCode) }
+
+// IsServerError returns whether the status code on the Response is a 5xx
+// server error
+func (r *Response) IsServerError() bool { return isServerError(r.res.StatusCode) }
+
+// Peek returns up to the first n bytes of the decoded response body without
+// consuming them, so that they're still read by Body(), Bytes(), JSON(...) etc
+// afterwards. Fewer than n bytes are returned without error if the body is
+// shorter. Readers returned by Body() before calling Peek don't see the peeked
+// bytes, so Body() must be called again after it
+func (r *Response) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		n = 0
+	}
+	b := make([]byte, n)
+	read, err := io.ReadFull(r.body, b)
+	b = b[:read]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	r.body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.body), r.body}
+	return b, err
+}
+
 // String attempts to return the decoded response as a string
 func (r *Response) String() (string, error) {
 	bytes, err := r.Bytes()
@@ -46,15 +189,114 @@ func (r *Response) String() (string, error) {
 
 // Bytes attempts to return the decoded response as bytes
 func (r *Response) Bytes() ([]byte, error) {
-	return ioutil.ReadAll(r.res.Body)
+	return ioutil.ReadAll(r.body)
 }
 
 // JSON attempts to JSON decode the response body into the passed interface
 func (r *Response) JSON(out interface{}) error {
-	return json.NewDecoder(r.res.Body).Decode(out)
+	return json.NewDecoder(r.body).Decode(out)
 }
 
 // XML attempts to XML decode the response body into the passed interface
 func (r *Response) XML(out interface{}) error {
-	return xml.NewDecoder(r.res.Body).Decode(out)
+	return xml.NewDecoder(r.body).Decode(out)
+}
+
+// Decode attempts to decode the response body into the passed interface using
+// the decoder matching the Content-Type of the response
+func (r *Response) Decode(out interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.ContentType())
+	if err != nil {
+		return fmt.Errorf("unable to decode content-type %q : %w", r.ContentType(), err)
+	}
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return r.JSON(out)
+	case mediaType == "application/xml", mediaType == "text/xml",
+		strings.HasSuffix(mediaType, "+xml"):
+		return r.XML(out)
+	case mediaType == "application/msgpack", mediaType == "application/x-msgpack":
+		return r.Msgpack(out)
+	}
+	return fmt.Errorf("unable to decode unsupported content-type %q", mediaType)
+}
+
+// isSuccess returns whether the passed status code is a 2xx success
+func isSuccess(statusCode int) bool { return statusCode >= 200 && statusCode < 300 }
+
+// isClientError returns whether the passed status code is a 4xx client error
+func isClientError(statusCode int) bool { return statusCode >= 400 && statusCode < 500 }
+
+// isServerError returns whether the passed status code is a 5xx server error
+func isServerError(statusCode int) bool { return statusCode >= 500 && statusCode < 600 }
+
+// decompressBody returns the body of the passed http Response wrapped in a
+// decompressing reader if its Content-Encoding is gzip or deflate. Bodies that
+// the transport has already decompressed no longer carry the header and are
+// returned as is
+func decompressBody(res *http.Response) io.ReadCloser {
+	var newReader func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		newReader = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		newReader = zlib.NewReader
+	default:
+		return res.Body
+	}
+
+	// Mirror the changes the standard library makes when decompressing
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return &decompressReader{body: res.Body, newReader: newReader}
+}
+
+// decompressReader is an io.ReadCloser that lazily decompresses the body it
+// wraps, so that empty bodies (HEAD, 204 etc) don't cause errors until read
+type decompressReader struct {
+	body      io.ReadCloser
+	newReader func(io.Reader) (io.ReadCloser, error)
+	r         io.ReadCloser
+	err       error
+}
+
+// Read reads decompressed bytes from the underlying body
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.newReader(d.body)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+// Close closes both the decompressing reader and the underlying body
+func (d *decompressReader) Close() error {
+	if d.r != nil {
+		d.r.Close()
+	}
+	return d.body.Close()
+}
+
+// limitedReader is an io.ReadCloser that returns ErrResponseTooLarge once more
+// than the limit of its LimitedReader (less the one extra byte used to detect
+// the overflow) has been read
+type limitedReader struct {
+	io.ReadCloser
+	r *io.LimitedReader
+}
+
+// Read reads from the underlying body until the limit is exceeded
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.r.N <= 0 {
+		if n > 0 {
+			n-- // Drop the byte read past the limit
+		}
+		return n, ErrResponseTooLarge
+	}
+	return n, err
 }
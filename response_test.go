@@ -0,0 +1,386 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponse_Decompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		writer   func(w io.Writer) io.WriteCloser
+	}{
+		{
+			name:     "gzip",
+			encoding: "gzip",
+			writer:   func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		},
+		{
+			name:     "deflate",
+			encoding: "deflate",
+			writer:   func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Encoding", tt.encoding)
+				zw := tt.writer(w)
+				zw.Write([]byte(`{"message":"some_message"}`))
+				zw.Close()
+			}))
+			defer s.Close()
+
+			// Setting Accept-Encoding stops the transport decompressing for us
+			var out struct {
+				Message string `json:"message"`
+			}
+			err := New().WithBaseURL(s.URL).Get("/").
+				WithHeader("Accept-Encoding", tt.encoding).
+				JSON(&out)
+			if err != nil {
+				t.Fatalf("Request.JSON() error = %v", err)
+			}
+			if out.Message != "some_message" {
+				t.Errorf("Request.JSON() decoded %q, want %q", out.Message, "some_message")
+			}
+		})
+	}
+}
+
+func TestResponse_Decode(t *testing.T) {
+	type body struct {
+		Message string `json:"message" xml:"message"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        body
+		wantErr     bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"message":"some_message"}`,
+			want:        body{Message: "some_message"},
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/problem+json",
+			body:        `{"message":"some_message"}`,
+			want:        body{Message: "some_message"},
+		},
+		{
+			name:        "xml",
+			contentType: "text/xml",
+			body:        `<body><message>some_message</message></body>`,
+			want:        body{Message: "some_message"},
+		},
+		{
+			name:        "unsupported",
+			contentType: "text/plain",
+			body:        "some_message",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer s.Close()
+
+			var out body
+			err := New().WithBaseURL(s.URL).Get("/").Decode(&out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out != tt.want {
+				t.Errorf("Request.Decode() decoded %v, want %v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_WithMaxResponseBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int64
+		want    string
+		wantErr error
+	}{
+		{
+			name: "unlimited",
+			want: "some_bytes",
+		},
+		{
+			name: "within limit",
+			max:  10,
+			want: "some_bytes",
+		},
+		{
+			name:    "exceeds limit",
+			max:     9,
+			wantErr: ErrResponseTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("some_bytes"))
+			}))
+			defer s.Close()
+
+			got, err := New().WithBaseURL(s.URL).Get("/").
+				WithMaxResponseBytes(tt.max).
+				String()
+			if err != tt.wantErr {
+				t.Fatalf("Request.String() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Request.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponse_IsSuccess(t *testing.T) {
+	tests := []struct {
+		name            string
+		statusCode      int
+		wantSuccess     bool
+		wantClientError bool
+		wantServerError bool
+	}{
+		{name: "ok", statusCode: http.StatusOK, wantSuccess: true},
+		{name: "no content", statusCode: http.StatusNoContent, wantSuccess: true},
+		{name: "redirect", statusCode: http.StatusFound},
+		{name: "not found", statusCode: http.StatusNotFound, wantClientError: true},
+		{name: "bad gateway", statusCode: http.StatusBadGateway, wantServerError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Response{res: &http.Response{StatusCode: tt.statusCode}}
+			if got := r.IsSuccess(); got != tt.wantSuccess {
+				t.Errorf("Response.IsSuccess() = %v, want %v", got, tt.wantSuccess)
+			}
+			if got := r.IsClientError(); got != tt.wantClientError {
+				t.Errorf("Response.IsClientError() = %v, want %v", got, tt.wantClientError)
+			}
+			if got := r.IsServerError(); got != tt.wantServerError {
+				t.Errorf("Response.IsServerError() = %v, want %v", got, tt.wantServerError)
+			}
+		})
+	}
+}
+
+func TestResponse_Trailer(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("some_body"))
+		w.Header().Set("X-Checksum", "some_checksum")
+	}))
+	defer s.Close()
+
+	res, err := New().WithBaseURL(s.URL).Get("/").Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	defer res.Close()
+	if _, err := res.Bytes(); err != nil {
+		t.Fatalf("Response.Bytes() error = %v", err)
+	}
+	if got := res.Trailer().Get("X-Checksum"); got != "some_checksum" {
+		t.Errorf("Response.Trailer() X-Checksum = %q, want %q", got, "some_checksum")
+	}
+}
+
+func TestResponse_Close(t *testing.T) {
+	var conns int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(32<<10))
+		w.Write(bytes.Repeat([]byte("a"), 32<<10))
+	}))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	s.Start()
+	defer s.Close()
+
+	c := New().WithBaseURL(s.URL)
+	for i := 0; i < 3; i++ {
+		res, err := c.Get("/").Do()
+		if err != nil {
+			t.Fatalf("Request.Do() error = %v", err)
+		}
+		if _, err := res.Body().Read(make([]byte, 1)); err != nil {
+			t.Fatalf("Response.Body().Read() error = %v", err)
+		}
+		first := res.Close()
+		if second := res.Close(); second != first {
+			t.Errorf("second Response.Close() = %v, want %v", second, first)
+		}
+	}
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("server accepted %d connections, want 1", got)
+	}
+}
+
+func TestResponse_CloseStream(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{}\n"))
+		w.(http.Flusher).Flush()
+		select { // Hold the chunked stream open
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer s.Close()
+	defer close(release)
+
+	res, err := New().WithBaseURL(s.URL).Get("/").WithTimeout(10 * time.Second).Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	if _, err := res.Body().Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Response.Body().Read() error = %v", err)
+	}
+	start := time.Now()
+	res.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Response.Close() took %v on a held open stream, want it to return promptly", elapsed)
+	}
+}
+
+func TestResponse_HeadContentLength(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodHead {
+			t.Errorf("server received method %s, want %s", req.Method, http.MethodHead)
+		}
+		w.Header().Set("Content-Length", "1234")
+	}))
+	defer s.Close()
+
+	res, err := New().WithBaseURL(s.URL).Head("/").Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	defer res.Close()
+	if res.StatusCode() != http.StatusOK {
+		t.Errorf("Response.StatusCode() = %d, want %d", res.StatusCode(), http.StatusOK)
+	}
+	if got := res.GetHeader("Content-Length"); got != "1234" {
+		t.Errorf("Response.GetHeader() Content-Length = %q, want %q", got, "1234")
+	}
+	if b, err := res.Bytes(); err != nil || len(b) != 0 {
+		t.Errorf("Response.Bytes() = %q %v, want an empty body", b, err)
+	}
+}
+
+func TestResponse_Peek(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{
+			name: "partial body",
+			n:    7,
+			want: `{"key":`,
+		},
+		{
+			name: "past the end of the body",
+			n:    64,
+			want: `{"key":"some_value"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(`{"key":"some_value"}`))
+			}))
+			defer s.Close()
+
+			res, err := New().WithBaseURL(s.URL).Get("/").Do()
+			if err != nil {
+				t.Fatalf("Request.Do() error = %v", err)
+			}
+			defer res.Close()
+			got, err := res.Peek(tt.n)
+			if err != nil {
+				t.Fatalf("Response.Peek() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Response.Peek() = %q, want %q", got, tt.want)
+			}
+			if again, _ := res.Peek(tt.n); string(again) != string(got) {
+				t.Errorf("second Response.Peek() = %q, want %q", again, got)
+			}
+			var out struct{ Key string }
+			if err := res.JSON(&out); err != nil || out.Key != "some_value" {
+				t.Errorf("Response.JSON() = %q %v, want %q", out.Key, err, "some_value")
+			}
+		})
+	}
+}
+
+func TestResponse_Cookie(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "some_session"})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+	}))
+	defer s.Close()
+
+	res, err := New().WithBaseURL(s.URL).Get("/").Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	defer res.Close()
+	if got := res.Cookies(); len(got) != 2 {
+		t.Errorf("Response.Cookies() = %v, want 2 cookies", got)
+	}
+	tests := []struct {
+		name    string
+		cookie  string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "present",
+			cookie: "theme",
+			want:   "dark",
+		},
+		{
+			name:    "missing",
+			cookie:  "missing",
+			wantErr: http.ErrNoCookie,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := res.Cookie(tt.cookie)
+			if err != tt.wantErr {
+				t.Fatalf("Response.Cookie() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Value != tt.want {
+				t.Errorf("Response.Cookie() = %q, want %q", got.Value, tt.want)
+			}
+		})
+	}
+}
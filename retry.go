@@ -0,0 +1,289 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// WithBackoff sets the delay between retries on the Request. The first retry
+// waits for the base duration and every retry after waits factor times longer
+// than the last (base * factor^N). When not set the default exponential
+// backoff of github.com/cenkalti/backoff is used
+func (r *Request) WithBackoff(base time.Duration, factor float64) *Request {
+	r.backoff = &backoff.ExponentialBackOff{
+		InitialInterval: base,
+		Multiplier:      factor,
+		MaxInterval:     time.Duration(math.MaxInt64),
+		Clock:           backoff.SystemClock,
+	}
+	return r
+}
+
+// WithMaxRetryAfter caps how long the Request will wait before retrying when a
+// Retry-After header is received. Retry-After takes precedence over the delay
+// set by WithBackoff(...) and by default is honored in full
+func (r *Request) WithMaxRetryAfter(max time.Duration) *Request {
+	r.maxRetryAfter = max
+	return r
+}
+
+// RetryPolicy decides whether another attempt should be made after the passed
+// attempt (starting at 1) resulted in the passed http Response or error
+type RetryPolicy func(res *http.Response, err error, attempt int) bool
+
+// WithRetryPolicy sets a RetryPolicy on the Request that replaces the default
+// decision of retrying on transport errors and unexpected status codes. The
+// number of attempts is still bounded by WithRetry(...), requests with a done
+// context are never retried, and success is still determined by
+// WithExpectedStatus(...) once retries have finished
+func (r *Request) WithRetryPolicy(policy RetryPolicy) *Request {
+	r.retryPolicy = policy
+	return r
+}
+
+// statusRange is an inclusive range of status codes
+type statusRange struct{ min, max int }
+
+// WithRetryOnStatus sets the passed status codes as retryable, in addition to
+// any set previously. Once any retryable status codes are set, only responses
+// with one of them are retried, whether or not they're expected with
+// WithExpectedStatus(...), which then solely decides whether the final
+// response is a success. Transport errors are always retried and a
+// RetryPolicy set with WithRetryPolicy(...) takes precedence over both
+func (r *Request) WithRetryOnStatus(codes ...int) *Request {
+	for _, code := range codes {
+		r.retryOn = append(r.retryOn, statusRange{min: code, max: code})
+	}
+	return r
+}
+
+// WithRetryOnStatusRange is identical to the WithRetryOnStatus(...) method but
+// sets every status code from min to max inclusive as retryable
+func (r *Request) WithRetryOnStatusRange(min, max int) *Request {
+	r.retryOn = append(r.retryOn, statusRange{min: min, max: max})
+	return r
+}
+
+// isRetryable returns whether the passed status code has been set as retryable
+func (r *Request) isRetryable(statusCode int) bool {
+	for _, sr := range r.retryOn {
+		if statusCode >= sr.min && statusCode <= sr.max {
+			return true
+		}
+	}
+	return false
+}
+
+// WithJitter randomizes each delay set by WithBackoff(...) within +/- the
+// passed fraction of it (0.2 being +/-20%), so that many clients retrying at
+// once don't do so in lockstep. Fractions are capped at 1 and it does nothing
+// unless WithBackoff(...) is also set
+func (r *Request) WithJitter(fraction float64) *Request {
+	r.jitter = fraction
+	return r
+}
+
+// newBackOff returns a freshly reset BackOff to be used for a single Do
+func (r *Request) newBackOff() backoff.BackOff {
+	if r.backoff == nil {
+		return backoff.NewExponentialBackOff()
+	}
+	b := *r.backoff
+	b.Reset()
+	if r.jitter > 0 {
+		return &jitterBackOff{BackOff: &b, fraction: r.jitter}
+	}
+	return &b
+}
+
+// jitterRand is the source of randomness used for jitter, seeded per-process
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// jitterBackOff is a BackOff that randomizes the delays of the BackOff it wraps
+type jitterBackOff struct {
+	backoff.BackOff
+	fraction float64
+}
+
+// NextBackOff returns the next delay of the wrapped BackOff with jitter applied
+func (j *jitterBackOff) NextBackOff() time.Duration {
+	d := j.BackOff.NextBackOff()
+	if d == backoff.Stop {
+		return d
+	}
+	return jitter(d, j.fraction)
+}
+
+// jitter randomizes the passed duration within +/- the passed fraction of it,
+// never returning a negative duration
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	jitterRand.Lock()
+	random := jitterRand.Float64()
+	jitterRand.Unlock()
+	return time.Duration(float64(d) * (1 + fraction*(2*random-1)))
+}
+
+// doRetry executes the passed http Request using the passed http Client and
+// retries as many times as specified on both transport errors and unexpected
+// status codes (or as dictated by the Requests RetryPolicy), waiting between
+// each attempt for the duration dictated by the Requests backoff or by a
+// Retry-After header on the response. The contexts error is returned as soon
+// as the context is done, both before each attempt and while waiting
+func (r *Request) doRetry(c *http.Client, req *http.Request) (*http.Response, error) {
+	b := r.newBackOff()
+
+	// Continuously retry HTTP requests
+	for tries := 1; ; tries++ {
+		// Abort as soon as the context is done rather than making another attempt
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+
+		// Rewind the body as the previous attempt will have consumed it
+		if tries > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		// Wait until the Limiter allows another attempt, if there is one
+		if r.limiter != nil {
+			if err := r.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		// Perform the request using the standard library
+		if r.observer != nil {
+			r.observer.OnAttempt(tries)
+		}
+		start := time.Now()
+		res, err := r.roundTrip(c, req)
+		if r.logger != nil {
+			event := LogEvent{
+				Method:   req.Method,
+				URL:      req.URL.String(),
+				Attempt:  tries,
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if res != nil {
+				event.StatusCode = res.StatusCode
+			}
+			r.logger(event)
+		}
+		if !r.shouldRetry(req, res, err, tries) {
+			return res, err
+		}
+
+		// Return the final attempt if we're out of retries or the body can't be
+		// replayed. Unexpected responses are returned so that their body may
+		// still be decoded
+		wait := b.NextBackOff()
+		if r.retryCount <= tries || wait == backoff.Stop || !canReplay(req) {
+			return res, err
+		}
+
+		// Otherwise discard the response and retry after waiting for the next
+		// backoff interval
+		var retryAfter time.Duration
+		var hasRetryAfter bool
+		if res != nil {
+			retryAfter, hasRetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+			res.Body.Close()
+		}
+		if hasRetryAfter {
+			wait = retryAfter
+			if r.maxRetryAfter > 0 && wait > r.maxRetryAfter {
+				wait = r.maxRetryAfter
+			}
+		}
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// canReplay returns whether the body of the passed http Request, if any, can be
+// replayed for another attempt
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// shouldRetry reports whether another attempt should be made after the passed
+// attempt resulted in the passed http Response or error. In order of
+// precedence the RetryPolicy decides, then transport errors are retried, then
+// retryable status codes are retried if any are set, and otherwise unexpected
+// status codes are retried
+func (r *Request) shouldRetry(req *http.Request, res *http.Response, err error, attempt int) bool {
+	if err != nil && req.Context().Err() != nil {
+		return false // Fail fast as a done context can never succeed
+	}
+	if r.retryPolicy != nil {
+		return r.retryPolicy(res, err, attempt)
+	}
+	if err != nil {
+		return true
+	}
+	if len(r.retryOn) > 0 {
+		return r.isRetryable(res.StatusCode)
+	}
+	return !r.isExpected(res.StatusCode)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given as either a
+// number of seconds or an HTTP-date, into the duration that should be waited
+// from now. The boolean reports whether a valid value was found
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// sleep pauses for the passed duration, returning the contexts error early if
+// the context is done before the duration has elapsed
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
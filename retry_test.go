@@ -0,0 +1,417 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+func TestRequest_WithBackoff(t *testing.T) {
+	type args struct {
+		base   time.Duration
+		factor float64
+	}
+	tests := []struct {
+		name        string
+		args        args
+		timeout     time.Duration
+		wantErr     error
+		wantMinimum time.Duration
+		wantMaximum time.Duration
+	}{
+		{
+			name:        "waits between retries",
+			args:        args{base: 20 * time.Millisecond, factor: 2},
+			timeout:     time.Minute,
+			wantMinimum: 60 * time.Millisecond,
+			wantMaximum: time.Second,
+		},
+		{
+			name:        "context cancelled while waiting",
+			args:        args{base: time.Hour, factor: 1},
+			timeout:     50 * time.Millisecond,
+			wantErr:     context.DeadlineExceeded,
+			wantMaximum: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer s.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := New().WithBaseURL(s.URL).Get("/").
+				WithContext(ctx).
+				WithExpectedStatus(http.StatusOK).
+				WithRetry(3).
+				WithBackoff(tt.args.base, tt.args.factor).
+				Error()
+			elapsed := time.Since(start)
+			if err == nil || (tt.wantErr != nil && err != tt.wantErr) {
+				t.Errorf("Request.Error() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed < tt.wantMinimum || elapsed > tt.wantMaximum {
+				t.Errorf("Request.Error() took %v, want between %v and %v", elapsed, tt.wantMinimum, tt.wantMaximum)
+			}
+		})
+	}
+}
+
+func TestRequest_WithMaxRetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		retryAfter  string
+		max         time.Duration
+		wantMinimum time.Duration
+		wantMaximum time.Duration
+	}{
+		{
+			name:        "retry after honored over backoff",
+			retryAfter:  "1",
+			wantMinimum: time.Second,
+			wantMaximum: 2 * time.Second,
+		},
+		{
+			name:        "retry after capped",
+			retryAfter:  "3600",
+			max:         50 * time.Millisecond,
+			wantMinimum: 50 * time.Millisecond,
+			wantMaximum: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if attempts++; attempts < 2 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer s.Close()
+
+			start := time.Now()
+			err := New().WithBaseURL(s.URL).Get("/").
+				WithExpectedStatus(http.StatusOK).
+				WithRetry(2).
+				WithBackoff(time.Millisecond, 1).
+				WithMaxRetryAfter(tt.max).
+				Error()
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("Request.Error() error = %v", err)
+			}
+			if elapsed < tt.wantMinimum || elapsed > tt.wantMaximum {
+				t.Errorf("Request.Error() took %v, want between %v and %v", elapsed, tt.wantMinimum, tt.wantMaximum)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	type args struct {
+		value string
+		now   time.Time
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name: "empty",
+			args: args{value: "", now: now},
+		},
+		{
+			name:   "seconds",
+			args:   args{value: "120", now: now},
+			want:   2 * time.Minute,
+			wantOk: true,
+		},
+		{
+			name: "negative seconds",
+			args: args{value: "-5", now: now},
+		},
+		{
+			name:   "http date",
+			args:   args{value: "Tue, 01 Jun 2021 12:00:30 GMT", now: now},
+			want:   30 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "http date in the past",
+			args:   args{value: "Tue, 01 Jun 2021 11:00:00 GMT", now: now},
+			want:   0,
+			wantOk: true,
+		},
+		{
+			name: "invalid",
+			args: args{value: "soon", now: now},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := parseRetryAfter(tt.args.value, tt.args.now)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRequest_WithRetryPolicy(t *testing.T) {
+	retryServerErrors := func(res *http.Response, err error, attempt int) bool {
+		return err != nil || res.StatusCode >= http.StatusInternalServerError
+	}
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{
+			name:         "server errors retried",
+			statuses:     []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			wantAttempts: 3,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "client errors not retried",
+			statuses:     []int{http.StatusNotFound, http.StatusOK},
+			wantAttempts: 1,
+			wantStatus:   http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+			}))
+			defer s.Close()
+
+			res, err := New().WithBaseURL(s.URL).Get("/").
+				WithRetry(5).
+				WithBackoff(time.Millisecond, 1).
+				WithRetryPolicy(retryServerErrors).
+				Do()
+			if err != nil {
+				t.Fatalf("Request.Do() error = %v", err)
+			}
+			defer res.Close()
+			if attempts != tt.wantAttempts || res.StatusCode() != tt.wantStatus {
+				t.Errorf("Request.Do() = %d after %d attempts, want %d after %d attempts",
+					res.StatusCode(), attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRequest_WithRetryOnStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      func(r *Request) *Request
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name: "retryable statuses retried",
+			request: func(r *Request) *Request {
+				return r.WithRetryOnStatus(http.StatusTooManyRequests).
+					WithRetryOnStatusRange(500, 599)
+			},
+			statuses:     []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name: "unexpected but not retryable",
+			request: func(r *Request) *Request {
+				return r.WithRetryOnStatusRange(500, 599).WithExpectedStatus(http.StatusOK)
+			},
+			statuses:     []int{http.StatusNotFound, http.StatusOK},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "expected but retryable",
+			request: func(r *Request) *Request {
+				return r.WithRetryOnStatus(http.StatusAccepted).WithExpectSuccess()
+			},
+			statuses:     []int{http.StatusAccepted, http.StatusOK},
+			wantAttempts: 2,
+		},
+		{
+			name: "policy takes precedence",
+			request: func(r *Request) *Request {
+				return r.WithRetryOnStatusRange(500, 599).
+					WithRetryPolicy(func(*http.Response, error, int) bool { return false })
+			},
+			statuses:     []int{http.StatusBadGateway, http.StatusOK},
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+			}))
+			defer s.Close()
+
+			r := New().WithBaseURL(s.URL).Get("/").WithRetry(5).WithBackoff(time.Millisecond, 1)
+			err := tt.request(r).Error()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Request.Error() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("server received %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	type args struct {
+		d        time.Duration
+		fraction float64
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantMinimum time.Duration
+		wantMaximum time.Duration
+	}{
+		{
+			name:        "no jitter",
+			args:        args{d: time.Second},
+			wantMinimum: time.Second,
+			wantMaximum: time.Second,
+		},
+		{
+			name:        "20 percent",
+			args:        args{d: time.Second, fraction: 0.2},
+			wantMinimum: 800 * time.Millisecond,
+			wantMaximum: 1200 * time.Millisecond,
+		},
+		{
+			name:        "capped fraction",
+			args:        args{d: time.Second, fraction: 5},
+			wantMinimum: 0,
+			wantMaximum: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := jitter(tt.args.d, tt.args.fraction); got < tt.wantMinimum || got > tt.wantMaximum {
+					t.Fatalf("jitter() = %v, want between %v and %v", got, tt.wantMinimum, tt.wantMaximum)
+				}
+			}
+		})
+	}
+}
+
+func TestRequest_WithJitter(t *testing.T) {
+	tests := []struct {
+		name        string
+		r           *Request
+		wantJitter  bool
+		wantMinimum time.Duration
+		wantMaximum time.Duration
+	}{
+		{
+			name:        "backoff without jitter",
+			r:           New().Get("/").WithBackoff(time.Second, 1),
+			wantMinimum: time.Second,
+			wantMaximum: time.Second,
+		},
+		{
+			name:        "backoff with jitter",
+			r:           New().Get("/").WithBackoff(time.Second, 1).WithJitter(0.2),
+			wantJitter:  true,
+			wantMinimum: 800 * time.Millisecond,
+			wantMaximum: 1200 * time.Millisecond,
+		},
+		{
+			name:        "capped fraction",
+			r:           New().Get("/").WithBackoff(time.Second, 1).WithJitter(5),
+			wantJitter:  true,
+			wantMinimum: 0,
+			wantMaximum: 2 * time.Second,
+		},
+		{
+			name: "jitter without backoff",
+			r:    New().Get("/").WithJitter(0.2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.r.newBackOff()
+			if _, got := b.(*jitterBackOff); got != tt.wantJitter {
+				t.Fatalf("Request.newBackOff() jitter = %v, want %v", got, tt.wantJitter)
+			}
+			if tt.r.backoff == nil {
+				if _, ok := b.(*backoff.ExponentialBackOff); !ok {
+					t.Errorf("Request.newBackOff() = %T, want the default %T", b, &backoff.ExponentialBackOff{})
+				}
+				return
+			}
+			seen := map[time.Duration]bool{}
+			for i := 0; i < 100; i++ {
+				got := b.NextBackOff()
+				if got < tt.wantMinimum || got > tt.wantMaximum {
+					t.Fatalf("BackOff.NextBackOff() = %v, want between %v and %v", got, tt.wantMinimum, tt.wantMaximum)
+				}
+				seen[got] = true
+			}
+			if got := len(seen) > 1; got != tt.wantJitter {
+				t.Errorf("BackOff.NextBackOff() randomized = %v, want %v", got, tt.wantJitter)
+			}
+		})
+	}
+}
+
+func TestRequest_doRetryDeadline(t *testing.T) {
+	var attempts int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := New().WithBaseURL(s.URL).Get("/").
+		WithContext(ctx).
+		WithExpectedStatus(http.StatusOK).
+		WithRetry(5).
+		WithBackoff(time.Second, 1).
+		Error()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Request.Error() returned after %v, want near the 100ms deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request.Error() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if attempts != 1 {
+		t.Errorf("server received %d attempts, want 1", attempts)
+	}
+}
@@ -0,0 +1,148 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEventLineSize is the maximum length of a single line in an event stream
+const maxEventLineSize = 1 << 20
+
+// Event is a single Server-Sent Event read from a text/event-stream response
+type Event struct {
+	ID    string        // The last event ID set by the stream
+	Event string        // The event type, "message" unless set by the stream
+	Data  string        // The data of the event, lines joined by "\n"
+	Retry time.Duration // The last reconnection time set by the stream, if any
+}
+
+// Events reads the response body as a text/event-stream, sending each Event
+// parsed on the returned Event channel. Both channels are closed once the
+// stream ends, reading fails or the request context is done, with the error
+// channel first receiving any error that occurred. Callers that stop reading
+// early must cancel the request context, and the Response must still be closed
+// once finished with
+func (r *Response) Events() (<-chan Event, <-chan error) {
+	ctx := context.Background()
+	if r.res.Request != nil {
+		ctx = r.res.Request.Context()
+	}
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errc)
+		if err := readEvents(ctx, r.body, events); err != nil {
+			errc <- err
+		}
+	}()
+	return events, errc
+}
+
+// readEvents parses the passed event stream as described by the HTML Living
+// Standard, sending every dispatched Event on the passed channel
+func readEvents(ctx context.Context, body io.Reader, events chan<- Event) error {
+	s := bufio.NewScanner(body)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxEventLineSize)
+	s.Split(scanEventLines)
+
+	var id, typ string
+	var retry time.Duration
+	var data []string
+	for first := true; s.Scan(); first = false {
+		line := s.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff") // Strip any byte order mark
+		}
+
+		// Blank lines dispatch the buffered event, if it has any data
+		if line == "" {
+			if data != nil {
+				e := Event{ID: id, Event: typ, Data: strings.Join(data, "\n"), Retry: retry}
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			typ, data = "", nil
+			continue
+		}
+
+		// Lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			typ = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if isDigits(value) {
+				if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+					retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// scanEventLines is a bufio.SplitFunc that splits an event stream into lines
+// terminated by any of CRLF, LF or CR
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		switch {
+		case data[i] == '\n':
+			return i + 1, data[:i], nil
+		case i+1 < len(data) && data[i+1] == '\n':
+			return i + 2, data[:i], nil
+		case i+1 < len(data) || atEOF:
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil // Wait to see if the CR is followed by a LF
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// isDigits returns whether the passed string is made up solely of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
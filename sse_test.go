@@ -0,0 +1,50 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResponse_Events(t *testing.T) {
+	stream := ": some comment\n" +
+		"data: first\n\n" +
+		"event: update\r\n" +
+		"id: 1\r\n" +
+		"data: multi\r\n" +
+		"data:line\r\n\r\n" +
+		"retry: 3000\r" +
+		"data: third\r\r" +
+		"id\n\n" +
+		"data: incomplete"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(stream))
+	}))
+	defer s.Close()
+
+	res, err := New().WithBaseURL(s.URL).Get("/").Do()
+	if err != nil {
+		t.Fatalf("Request.Do() error = %v", err)
+	}
+	defer res.Close()
+
+	var got []Event
+	events, errc := res.Events()
+	for e := range events {
+		got = append(got, e)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Response.Events() error = %v", err)
+	}
+	want := []Event{
+		{Event: "message", Data: "first"},
+		{ID: "1", Event: "update", Data: "multi\nline"},
+		{ID: "1", Event: "message", Data: "third", Retry: 3 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Response.Events() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package httpclient /* import "s32x.com/httpclient" */
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"h12.io/socks"
+)
+
+// ErrUnsupportedTransport is returned when a Request needs to clone the
+// Transport of its http Client in order to be performed, but the Transport
+// isn't an *http.Transport
+var ErrUnsupportedTransport = errors.New("the http Client Transport must be an *http.Transport")
+
+// transports holds the Transports cloned for each combination of base
+// Transport, client certificate and proxy so that they, and their pooled
+// connections, are reused by every Request sent with the same configuration
+var transports sync.Map // map[transportKey]*http.Transport
+
+// transportKey is the key of a Transport stored in transports
+type transportKey struct {
+	base  http.RoundTripper
+	cert  string // The concatenated DER encoded certificate chain, if any
+	proxy string // The proxy URL, if any
+}
+
+// transport returns the cached clone of the passed base Transport that
+// presents the passed client certificate and sends requests through the passed
+// proxy, either of which may be nil, cloning it if there isn't one yet
+func transport(base http.RoundTripper, cert *tls.Certificate, proxy *url.URL) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return nil, ErrUnsupportedTransport
+	}
+	key := transportKey{base: base}
+	if cert != nil {
+		key.cert = string(bytes.Join(cert.Certificate, nil))
+	}
+	if proxy != nil {
+		key.proxy = proxy.String()
+	}
+	if clone, ok := transports.Load(key); ok {
+		return clone.(*http.Transport), nil
+	}
+
+	clone := t.Clone()
+	if cert != nil {
+		if clone.TLSClientConfig == nil {
+			clone.TLSClientConfig = &tls.Config{}
+		}
+		clone.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	}
+	if proxy != nil {
+		if proxy.Scheme == "socks4" {
+			dial := socks.Dial(proxy.String())
+			clone.Proxy = nil
+			clone.DialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
+				return dial(network, addr)
+			}
+		} else {
+			clone.Proxy = http.ProxyURL(proxy)
+		}
+	}
+	stored, _ := transports.LoadOrStore(key, clone)
+	return stored.(*http.Transport), nil
+}